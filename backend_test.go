@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBitBackend tests the bit-packed backend against the same 16x16, 64x64 and 512x512 images as TestGol,
+// on 0, 1 and 100 turns using 1-16 worker threads.
+func TestBitBackend(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		p.Backend = gol.BitBackend
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			for threads := 1; threads <= 16; threads++ {
+				p.Threads = threads
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				t.Run(testName, func(t *testing.T) {
+					assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+				})
+			}
+		}
+	}
+}
+
+// TestBitBackendRules tests the bit-packed backend on the rule and topology images of TestRule and TestTopology.
+func TestBitBackendRules(t *testing.T) {
+	tests := []struct {
+		name string
+		p    gol.Params
+	}{
+		{ruleName(gol.HighLife), gol.Params{Rule: gol.HighLife}},
+		{ruleName(gol.DayNight), gol.Params{Rule: gol.DayNight}},
+		{ruleName(gol.Seeds), gol.Params{Rule: gol.Seeds}},
+		{gol.Plane.String(), gol.Params{Topology: gol.Plane}},
+		{gol.Cylinder.String(), gol.Params{Topology: gol.Cylinder}},
+		{gol.KleinBottle.String(), gol.Params{Topology: gol.KleinBottle}},
+		{gol.CrossSurface.String(), gol.Params{Topology: gol.CrossSurface}},
+	}
+	for _, test := range tests {
+		p := test.p
+		p.Backend = gol.BitBackend
+		p.ImageWidth, p.ImageHeight, p.Turns = 64, 64, 100
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v-%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns, test.name),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, threads := range []int{1, 3, 8} {
+			p.Threads = threads
+			testName := fmt.Sprintf("%v-%dx%dx%d-%d", test.name, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// runFinalAlive runs the Game of Life to completion and returns the alive cells of the final turn.
+// The test fails if the run reports an error.
+func runFinalAlive(t *testing.T, p gol.Params) []util.Cell {
+	t.Helper()
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		case gol.Error:
+			t.Errorf("run failed after %v turns: %v", e.CompletedTurns, e.Err)
+		}
+	}
+	return cells
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+const benchLength = 100
+
+// BenchmarkBackend compares the byte and bit-packed backends on a 512x512 image using 1-16 worker threads.
+func BenchmarkBackend(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	for _, backend := range []gol.Backend{gol.ByteBackend, gol.BitBackend} {
+		for threads := 1; threads <= 16; threads *= 2 {
+			p := gol.Params{
+				Turns:       benchLength,
+				Threads:     threads,
+				ImageWidth:  512,
+				ImageHeight: 512,
+				Backend:     backend,
+			}
+			name := fmt.Sprintf("%v-%dx%dx%d-%d", backend, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					for range events {
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkWorkers compares the ways worker threads can share the world on a 512x512 image using 1-16 worker threads.
+func BenchmarkWorkers(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	for _, workers := range []gol.WorkerMode{gol.SpawnWorkers, gol.PoolWorkers, gol.HaloWorkers} {
+		for threads := 1; threads <= 16; threads++ {
+			p := gol.Params{
+				Turns:       benchLength,
+				Threads:     threads,
+				ImageWidth:  512,
+				ImageHeight: 512,
+				Workers:     workers,
+			}
+			name := fmt.Sprintf("%v-%dx%dx%d-%d", workers, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					for range events {
+					}
+				}
+			})
+		}
+	}
+}
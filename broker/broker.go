@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// main starts a broker, which runs simulations for controllers started with -broker using the registered workers.
+func main() {
+	port := flag.String(
+		"port",
+		"8030",
+		"Specify the port to listen on for controllers and workers. Defaults to 8030.")
+
+	flag.Parse()
+
+	listener, err := net.Listen("tcp", ":"+*port)
+	util.Check(err)
+	fmt.Println("Broker listening on", listener.Addr())
+	util.Check(gol.ServeBroker(listener))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCheckpoint writes checkpoints every 30 turns of a simulation under Brian's Brain and on a plane,
+// resumes from the one after 60 turns without giving the rule or topology, and checks that the simulation
+// carries on from turn 60 to the expected image.
+func TestCheckpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		p    gol.Params
+	}{
+		{ruleName(gol.BriansBrain), gol.Params{Rule: gol.BriansBrain}},
+		{"plane", gol.Params{Topology: gol.Plane}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.p
+			p.ImageWidth, p.ImageHeight, p.Threads, p.Turns = 16, 16, 4, 100
+			p.CheckpointEvery = 30
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var checkpoints []int
+			for event := range events {
+				if e, ok := event.(gol.CheckpointComplete); ok {
+					checkpoints = append(checkpoints, e.CompletedTurns)
+				}
+			}
+			if fmt.Sprint(checkpoints) != "[30 60 90]" {
+				t.Fatalf("expected checkpoints after 30, 60 and 90 turns, but got %v", checkpoints)
+			}
+
+			resumed := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 4, Turns: 100, Resume: "out/16x16x60.checkpoint"}
+			events = make(chan gol.Event)
+			go gol.Run(resumed, events, nil)
+			first, final := -1, -1
+			for event := range events {
+				switch e := event.(type) {
+				case gol.TurnComplete:
+					if first == -1 {
+						first = e.CompletedTurns
+					}
+				case gol.FinalTurnComplete:
+					final = e.CompletedTurns
+				}
+			}
+			if first != 60 || final != 100 {
+				t.Errorf("expected the resumed simulation to compute turns 61 to 100, but it computed turns %v to %v", first+1, final)
+			}
+			expected := readPgmBytes(fmt.Sprintf("check/images/16x16x100-%v.pgm", test.name))
+			if !bytes.Equal(readPgmBytes("out/16x16x100.pgm"), expected) {
+				t.Errorf("resumed simulation did not produce the expected image")
+			}
+		})
+	}
+}
+
+// TestCheckpointName resumes from a checkpoint of a pattern whose file name holds a space,
+// and checks that the name is carried over into the names of the images written after resuming.
+func TestCheckpointName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "my pattern.rle")
+	if err := ioutil.WriteFile(input, []byte("x = 3, y = 3\nbob$2bo$3o!\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 4, Threads: 4, ImageWidth: 8, ImageHeight: 8, InputPath: input, OutputDir: dir,
+		OutputTemplate: "{name}-{turn}.pgm", CheckpointEvery: 2}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+
+	p.InputPath, p.CheckpointEvery = "", 0
+	p.Resume = filepath.Join(dir, "my pattern-2.checkpoint")
+	events = make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		if e, ok := event.(gol.Error); ok {
+			t.Errorf("resuming from %v failed: %v", p.Resume, e.Err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "my pattern-4.pgm")); err != nil {
+		t.Errorf("the resumed simulation did not keep the name of its input: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestRunContext stops runs of the 512x512 image with a deadline, including one that is paused, and checks that
+// they finish with the usual final events and leave no goroutines behind.
+func TestRunContext(t *testing.T) {
+	alive := readAliveCounts(512, 512)
+	tests := map[string]gol.Params{
+		"halo":   {Workers: gol.HaloWorkers},
+		"pool":   {Workers: gol.PoolWorkers},
+		"spawn":  {Workers: gol.SpawnWorkers},
+		"paused": {},
+	}
+	for name, p := range tests {
+		p.Turns, p.Threads, p.ImageWidth, p.ImageHeight = 100000000, 8, 512, 512
+		p.Broker = broker
+		t.Run(name, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			events := make(chan gol.Event)
+			keyPresses := make(chan rune, 1)
+			if name == "paused" {
+				keyPresses <- 'p'
+			}
+			result := make(chan error, 1)
+			go func() {
+				result <- gol.RunContext(ctx, p, events, keyPresses)
+			}()
+
+			var final *gol.FinalTurnComplete
+			saved, quit := false, false
+			for event := range events {
+				switch e := event.(type) {
+				case gol.FinalTurnComplete:
+					final = &e
+				case gol.ImageOutputComplete:
+					saved = final != nil && e.CompletedTurns == final.CompletedTurns
+				case gol.StateChange:
+					quit = e.NewState == gol.Quitting
+				}
+			}
+			if err := <-result; err != context.DeadlineExceeded {
+				t.Errorf("expected RunContext to return %v, got %v", context.DeadlineExceeded, err)
+			}
+			if final == nil || !saved || !quit {
+				t.Fatalf("expected FinalTurnComplete, the final image and Quitting, got %v, %v and %v", final != nil, saved, quit)
+			}
+			if expected, ok := alive[final.CompletedTurns]; ok && expected != len(final.Alive) {
+				t.Errorf("expected %v alive cells after %v turns, got %v", expected, final.CompletedTurns, len(final.Alive))
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if leaked := runtime.NumGoroutine() - goroutines; leaked > 0 {
+				t.Errorf("%v goroutines are still running after RunContext returned", leaked)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// broker is the address of the broker that TestGol, TestAlive and TestPgm run their simulations on.
+// It is empty when they run locally.
+var broker string
+
+// TestDistributed runs TestGol, TestAlive and TestPgm on a broker with two workers, each in its own process.
+func TestDistributed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping distributed tests in short mode")
+	}
+	c := startCluster(t, 2)
+	defer c.stop()
+
+	broker = c.address
+	defer func() { broker = "" }()
+	t.Run("TestGol", TestGol)
+	t.Run("TestAlive", TestAlive)
+	t.Run("TestPgm", TestPgm)
+	t.Run("TestErrors", TestErrors)
+	t.Run("TestRunContext", TestRunContext)
+}
+
+// TestDetach detaches a controller from a simulation with 'q', attaches a new one and checks that it is sent
+// the current board and the alive cell counts, and then shuts everything down with 'k'.
+func TestDetach(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping distributed tests in short mode")
+	}
+	c := startCluster(t, 2)
+	defer c.stop()
+	alive := readAliveCounts(512, 512)
+	p := gol.Params{
+		Turns:       100000000,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		Broker:      c.address,
+	}
+
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	go gol.Run(p, events, keyPresses)
+	turns := 0
+	for event := range events {
+		if _, ok := event.(gol.TurnComplete); ok {
+			turns++
+			if turns == 10 {
+				keyPresses <- 'q'
+			}
+		}
+	}
+	if turns < 10 {
+		t.Fatalf("simulation finished after %v turns", turns)
+	}
+
+	p.Attach = true
+	events = make(chan gol.Event)
+	keyPresses = make(chan rune, 1)
+	go gol.Run(p, events, keyPresses)
+	board := make([][]uint8, p.ImageHeight)
+	for y := range board {
+		board[y] = make([]uint8, p.ImageWidth)
+	}
+	turn, counts, final := -1, 0, false
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			board[e.Cell.Y][e.Cell.X] = e.Value
+		case gol.TurnComplete:
+			if turn == -1 && e.CompletedTurns < turns-1 {
+				t.Fatalf("attached at turn %v, before the controller detached at turn %v", e.CompletedTurns, turns-1)
+			}
+			turn = e.CompletedTurns + 1
+			if expected, ok := alive[turn]; ok && countAlive(board) != expected {
+				t.Fatalf("board has %v alive cells after %v turns, expected %v", countAlive(board), turn, expected)
+			}
+		case gol.AliveCellsCount:
+			if e.CompletedTurns != turn {
+				t.Fatalf("count for turn %v received after turn %v", e.CompletedTurns, turn)
+			}
+			if expected, ok := alive[turn]; ok && e.CellsCount != expected {
+				t.Fatalf("count of %v alive cells after %v turns, expected %v", e.CellsCount, turn, expected)
+			}
+			counts++
+			if counts == 2 {
+				keyPresses <- 'k'
+			}
+		case gol.FinalTurnComplete:
+			final = true
+		}
+	}
+	if counts < 2 || !final {
+		t.Fatalf("simulation finished after %v counts, final turn received: %v", counts, final)
+	}
+	if !c.wait(10 * time.Second) {
+		t.Fatal("broker and workers did not shut down within 10 seconds")
+	}
+}
+
+// TestFaultTolerance kills two of three workers part way through a simulation and checks that the broker
+// still completes it correctly.
+func TestFaultTolerance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping distributed tests in short mode")
+	}
+	c := startCluster(t, 3)
+	defer c.stop()
+	kill := func(worker int) func() {
+		return func() {
+			if err := c.processes[worker].Process.Kill(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	runWithFaults(t, c, map[int]func(){20: kill(1), 50: kill(2)})
+}
+
+// runWithFaults runs 100 turns of the 512x512 image on the cluster, calling the fault for a turn as soon as
+// that turn is complete, and checks the final board against the expected image.
+func runWithFaults(t *testing.T, c *cluster, faults map[int]func()) {
+	p := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		Broker:      c.address,
+	}
+	expected := readAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if fault, ok := faults[e.CompletedTurns+1]; ok {
+				fault()
+			}
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	assertEqualBoard(t, cells, expected, p)
+}
+
+func countAlive(board [][]uint8) int {
+	count := 0
+	for _, row := range board {
+		for _, cell := range row {
+			if cell == 255 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// cluster is a broker and its workers, each running in its own process.
+type cluster struct {
+	dir       string
+	address   string
+	processes []*exec.Cmd
+	exited    chan bool
+}
+
+// startCluster builds the broker and worker commands, and starts a broker and the given number of workers.
+func startCluster(t *testing.T, workers int) *cluster {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &cluster{dir: dir, address: freeAddress(t), exited: make(chan bool)}
+	build := exec.Command("go", "build", "-o", dir, "./broker", "./worker")
+	if output, err := build.CombinedOutput(); err != nil {
+		c.stop()
+		t.Fatalf("building broker and worker: %v\n%s", err, output)
+	}
+	_, port, _ := net.SplitHostPort(c.address)
+	c.start(t, "Broker listening", filepath.Join(dir, "broker"), "-port", port)
+	for i := 0; i < workers; i++ {
+		c.start(t, "Registered with broker", filepath.Join(dir, "worker"), "-address", freeAddress(t), "-broker", c.address)
+	}
+	return c
+}
+
+// stop kills the processes that are still running and removes the commands.
+func (c *cluster) stop() {
+	for _, process := range c.processes {
+		_ = process.Process.Kill()
+	}
+	c.wait(10 * time.Second)
+	os.RemoveAll(c.dir)
+}
+
+// wait waits for every process to exit, and reports whether they did so within the timeout.
+func (c *cluster) wait(timeout time.Duration) bool {
+	timer := time.After(timeout)
+	for range c.processes {
+		select {
+		case <-c.exited:
+		case <-timer:
+			return false
+		}
+	}
+	c.processes = nil
+	return true
+}
+
+// freeAddress returns a localhost address with a port that is not in use.
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// start starts a command and waits until it prints a line containing ready.
+// The rest of its output is discarded.
+func (c *cluster) start(t *testing.T, ready string, name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		c.stop()
+		t.Fatal(err)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		c.stop()
+		t.Fatal(err)
+	}
+	c.processes = append(c.processes, cmd)
+	readyCh := make(chan bool)
+	go func() {
+		output := bufio.NewScanner(stdout)
+		found := false
+		for output.Scan() {
+			if !found && strings.Contains(output.Text(), ready) {
+				close(readyCh)
+				found = true
+			}
+		}
+		_ = cmd.Wait()
+		c.exited <- true
+	}()
+	select {
+	case <-readyCh:
+	case <-time.After(10 * time.Second):
+		c.stop()
+		t.Fatalf("%v did not print %q within 10 seconds", filepath.Base(name), ready)
+	}
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHungWorker stops one of two workers part way through a simulation, so that it no longer answers heartbeats,
+// and checks that the broker still completes the simulation correctly.
+func TestHungWorker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping distributed tests in short mode")
+	}
+	c := startCluster(t, 2)
+	defer c.stop()
+	runWithFaults(t, c, map[int]func(){30: func() {
+		if err := c.processes[1].Process.Signal(syscall.SIGSTOP); err != nil {
+			t.Fatal(err)
+		}
+	}})
+}
+
+// TestRebalance adds a worker to a simulation running on one worker and then interrupts the first one,
+// and checks that the broker reports each change and still completes the simulation correctly.
+func TestRebalance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping distributed tests in short mode")
+	}
+	c := startCluster(t, 1)
+	defer c.stop()
+	p := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		Broker:      c.address,
+	}
+	expected := readAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	var workers []int
+	for event := range events {
+		switch e := event.(type) {
+		case gol.WorkersChanged:
+			workers = append(workers, e.Workers)
+		case gol.TurnComplete:
+			switch e.CompletedTurns + 1 {
+			case 20:
+				c.start(t, "Registered with broker", filepath.Join(c.dir, "worker"), "-address", freeAddress(t), "-broker", c.address)
+			case 50:
+				if err := c.processes[1].Process.Signal(os.Interrupt); err != nil {
+					t.Fatal(err)
+				}
+			}
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	if len(workers) != 3 || workers[0] != 1 || workers[1] != 2 || workers[2] != 1 {
+		t.Errorf("expected the simulation to run on 1, 2 and then 1 workers, but it ran on %v", workers)
+	}
+	assertEqualBoard(t, cells, expected, p)
+}
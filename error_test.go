@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestErrors runs simulations that cannot be loaded or saved, and checks that Run returns an error
+// that is also sent as the last event before the channel is closed. On a broker the error is sent to the controller.
+func TestErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"truncated.pgm":     "P5\n16 16\n255\n\x00\x00",
+		"bad.checkpoint":    "not a checkpoint\n",
+		"not-a-directory":   "",
+		"wide.checkpoint":   "",
+		"empty-pattern.rle": "",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// wide.checkpoint is a real checkpoint of a 16x16 world, which cannot be resumed as a 64x64 one.
+	events := make(chan gol.Event)
+	go gol.Run(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16, OutputDir: dir,
+		OutputTemplate: "wide.pgm", CheckpointEvery: 1}, events, nil)
+	for range events {
+	}
+
+	tests := []struct {
+		name     string
+		p        gol.Params
+		expected string
+	}{
+		{"missing input", gol.Params{InputPath: filepath.Join(dir, "missing.pgm")}, "no such file"},
+		{"truncated input", gol.Params{InputPath: filepath.Join(dir, "truncated.pgm")}, "image data ends in row 1 of 16"},
+		{"bad pattern", gol.Params{InputPath: filepath.Join(dir, "empty-pattern.rle"), ImageWidth: 16, ImageHeight: 16}, "no header"},
+		{"bad checkpoint", gol.Params{Resume: filepath.Join(dir, "bad.checkpoint"), ImageWidth: 16, ImageHeight: 16}, "not a checkpoint file"},
+		{"wrong checkpoint size", gol.Params{Resume: filepath.Join(dir, "wide.checkpoint"), ImageWidth: 64, ImageHeight: 64}, "is 16x16, not 64x64"},
+		{"bad output template", gol.Params{ImageWidth: 16, ImageHeight: 16, OutputTemplate: "{turn"}, "unbalanced braces"},
+		{"unwritable output", gol.Params{ImageWidth: 16, ImageHeight: 16, OutputDir: filepath.Join(dir, "not-a-directory")}, "not a directory"},
+		{"no broker", gol.Params{ImageWidth: 16, ImageHeight: 16, Broker: "127.0.0.1:1"}, "refused"},
+	}
+	for _, test := range tests {
+		test.p.Turns, test.p.Threads = 10, 4
+		if test.p.Broker == "" {
+			test.p.Broker = broker
+		}
+		t.Run(test.name, func(t *testing.T) {
+			events := make(chan gol.Event)
+			result := make(chan error, 1)
+			go func() {
+				result <- gol.Run(test.p, events, nil)
+			}()
+			var last gol.Event
+			for event := range events {
+				last = event
+			}
+			err := <-result
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("expected an error containing %q, got %v", test.expected, err)
+			}
+			if e, ok := last.(gol.Error); !ok || e.Err != err {
+				t.Errorf("expected the last event to be an Error event with the error returned, got %#v", last)
+			}
+		})
+	}
+}
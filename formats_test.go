@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestConvert converts the 64x64 image to RLE, plaintext and back to pgm and checks that it is unchanged,
+// and converts a Life 1.06 glider with negative coordinates to plaintext.
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := []string{"images/64x64.pgm"}
+	for _, name := range []string{"64x64.rle", "64x64.cells", "64x64.pgm"} {
+		path := filepath.Join(dir, name)
+		if err := gol.Convert(paths[len(paths)-1], path); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	original, _ := ioutil.ReadFile(paths[0])
+	converted, _ := ioutil.ReadFile(paths[len(paths)-1])
+	if !bytes.Equal(original, converted) {
+		t.Errorf("converting %v to RLE, plaintext and back to pgm changed it", paths[0])
+	}
+
+	glider := filepath.Join(dir, "glider.lif")
+	if err := ioutil.WriteFile(glider, []byte("#Life 1.06\n0 -1\n1 0\n-1 1\n0 1\n1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cells := filepath.Join(dir, "glider.cells")
+	if err := gol.Convert(glider, cells); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(cells); string(data) != ".O.\n..O\nOOO\n" {
+		t.Errorf("glider converted from Life 1.06 to plaintext is\n%s", data)
+	}
+}
+
+// TestPatternFormats loads a glider from plaintext and Life 1.06 patterns into the middle of an 8x8 world.
+func TestPatternFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	patterns := map[string]string{
+		"glider.cells": "!Name: Glider\n.O\n..O\nOOO\n",
+		"glider.lif":   "#Life 1.06\n#D A glider\n1 0\n2 1\n0 2\n1 2\n2 2\n",
+	}
+	expected := []util.Cell{{X: 3, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 4}, {X: 3, Y: 4}, {X: 4, Y: 4}}
+	for name, pattern := range patterns {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(pattern), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p := gol.Params{Threads: 4, ImageWidth: 8, ImageHeight: 8, InputPath: path, OutputDir: dir}
+		t.Run(name, func(t *testing.T) {
+			assertEqualBoard(t, runFinalAlive(t, p), expected, p)
+		})
+	}
+}
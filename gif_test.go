@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestGif records every 33rd turn of 100 turns of the 16x16 image at a scale of 2, and checks the frames
+// after 1 and 100 turns against the expected cells.
+func TestGif(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: dir}
+	options := gol.GifOptions{
+		Path:   filepath.Join(dir, "16x16.gif"),
+		From:   1,
+		Stride: 33,
+		Scale:  2,
+		Delay:  50 * time.Millisecond,
+	}
+	events := make(chan gol.Event)
+	forwarded := make(chan gol.Event)
+	recorded := make(chan error, 1)
+	go func() {
+		recorded <- gol.RecordGif(options, p.ImageWidth, p.ImageHeight, events, forwarded)
+	}()
+	go gol.Run(p, events, nil)
+	final := 0
+	for event := range forwarded {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e.CompletedTurns
+		}
+	}
+	if err := <-recorded; err != nil {
+		t.Fatal(err)
+	}
+	if final != 100 {
+		t.Errorf("expected the events to be forwarded up to FinalTurnComplete after 100 turns, got %v", final)
+	}
+
+	file, err := os.Open(options.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != 4 {
+		t.Fatalf("expected frames after turns 1, 34, 67 and 100, got %v frames", len(animation.Image))
+	}
+	for i, turns := range map[int]int{0: 1, 3: 100} {
+		frame := animation.Image[i]
+		if size := frame.Bounds().Size(); size.X != 32 || size.Y != 32 {
+			t.Fatalf("expected 32x32 frames, got %vx%v", size.X, size.Y)
+		}
+		if animation.Delay[i] != 5 {
+			t.Errorf("expected a delay of 5 hundredths of a second, got %v", animation.Delay[i])
+		}
+		var cells []util.Cell
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if frame.ColorIndexAt(2*x+1, 2*y+1) == 255 {
+					cells = append(cells, util.Cell{X: x, Y: y})
+				}
+			}
+		}
+		assertEqualBoard(t, cells, readAliveCells(fmt.Sprintf("check/images/16x16x%v.pgm", turns), 16, 16), p)
+	}
+}
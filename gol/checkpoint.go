@@ -0,0 +1,161 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A checkpoint file holds everything needed to carry on a simulation from the turn it was written on.
+// It starts with a text header of one "key value" pair per line, ended by an empty line, followed by
+// width x height bytes holding the grey level of every cell, row by row. The name is quoted as a Go string,
+// since it is taken from the input file and may hold spaces:
+//
+//	GOL checkpoint 1
+//	name "512x512"
+//	width 512
+//	height 512
+//	turn 100
+//	rule B3/S23
+//	topology torus
+//
+// The simulation is deterministic, so nothing else is needed to compute the same turns again.
+const checkpointMagic = "GOL checkpoint 1"
+
+// checkpoint is the state of a simulation after Turn turns. Name is the name of the input it was loaded from.
+type checkpoint struct {
+	Name          string
+	Width, Height int
+	Turn          int
+	Rule          Rule
+	Topology      Topology
+	Field         [][]uint8
+}
+
+// writeTo writes the checkpoint in the checkpoint file format.
+func (cp checkpoint) writeTo(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%v\nname %q\nwidth %v\nheight %v\nturn %v\nrule %v\ntopology %v\n\n",
+		checkpointMagic, cp.Name, cp.Width, cp.Height, cp.Turn, cp.Rule, cp.Topology)
+	for _, row := range cp.Field {
+		if _, err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// readCheckpoint reads a checkpoint in the checkpoint file format of a width x height world.
+// Checkpoints of other sizes are rejected from their header, before their cells are allocated.
+func readCheckpoint(r io.Reader, width, height int) (checkpoint, error) {
+	var cp checkpoint
+	in := bufio.NewReader(r)
+	magic, err := in.ReadString('\n')
+	if err != nil || strings.TrimSpace(magic) != checkpointMagic {
+		return cp, errors.New("not a checkpoint file")
+	}
+	cp.Rule = Conway
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return cp, fmt.Errorf("reading checkpoint header: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return cp, fmt.Errorf("invalid checkpoint header line %q", line)
+		}
+		switch fields[0] {
+		case "name":
+			cp.Name, err = strconv.Unquote(fields[1])
+		case "width":
+			cp.Width, err = strconv.Atoi(fields[1])
+		case "height":
+			cp.Height, err = strconv.Atoi(fields[1])
+		case "turn":
+			cp.Turn, err = strconv.Atoi(fields[1])
+		case "rule":
+			err = cp.Rule.Set(fields[1])
+		case "topology":
+			err = cp.Topology.Set(fields[1])
+		}
+		// Unknown keys are skipped, so that later versions can add to the header.
+		if err != nil {
+			return cp, fmt.Errorf("invalid checkpoint header line %q: %v", line, err)
+		}
+	}
+	if cp.Name == "" {
+		cp.Name = fmt.Sprintf("%vx%v", cp.Width, cp.Height)
+	}
+	if cp.Width <= 0 || cp.Height <= 0 || cp.Turn < 0 {
+		return cp, errors.New("checkpoint has an invalid size or turn")
+	}
+	if err := checkPatternSize(cp.Width, cp.Height); err != nil {
+		return cp, err
+	}
+	if cp.Width != width || cp.Height != height {
+		return cp, fmt.Errorf("checkpoint is %dx%d, not %dx%d", cp.Width, cp.Height, width, height)
+	}
+	cp.Field = newField(cp.Height, cp.Width)
+	for _, row := range cp.Field {
+		if _, err := io.ReadFull(in, row); err != nil {
+			return cp, fmt.Errorf("reading checkpoint cells: %v", err)
+		}
+	}
+	return cp, nil
+}
+
+// writeCheckpointFile receives a checkpoint and writes it to a checkpoint file.
+func (io *ioState) writeCheckpointFile() error {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+	cp := <-io.channels.checkpointOutput
+
+	file, ioError := io.createOutput(filename)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+
+	if ioError = cp.writeTo(file); ioError != nil {
+		return fmt.Errorf("writing %v: %v", filename, ioError)
+	}
+	if ioError = file.commit(); ioError != nil {
+		return ioError
+	}
+
+	fmt.Println("Checkpoint", filename, "output done!")
+	return nil
+}
+
+// readCheckpointFile opens a checkpoint file and sends the checkpoint it holds, or nothing if it cannot be read.
+func (io *ioState) readCheckpointFile() error {
+
+	// Request a path from the distributor.
+	path := <-io.channels.filename
+
+	file, ioError := os.Open(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+
+	cp, ioError := readCheckpoint(file, io.params.ImageWidth, io.params.ImageHeight)
+	if ioError != nil {
+		return fmt.Errorf("reading %v: %v", path, ioError)
+	}
+
+	// Images written from now on use the rule of the checkpoint.
+	io.params.Rule = cp.Rule
+	io.channels.checkpointInput <- cp
+
+	fmt.Println("Checkpoint", path, "input done!")
+	return nil
+}
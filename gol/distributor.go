@@ -1,6 +1,7 @@
 package gol
 
 import (
+    "context"
     "strconv"
     "sync"
     "fmt"
@@ -14,26 +15,55 @@ type distributorChannels struct {
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
 	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	ioOutput   chan<- []uint8
+	ioInput    <-chan []uint8
+	ioCheckpointOutput chan<- checkpoint
+	ioCheckpointInput  <-chan checkpoint
+	ioErrors           <-chan error
     	keyPresses <-chan rune
 }
 
-var b = NewBroker()
-
 type World struct {
 	field [][]uint8
 	height, width, threads int
+	rule Rule
+	topology Topology
+	backend Backend
+	// bits holds the bit-packed rows of the field when using BitBackend.
+	bits [][]uint64
+	// next and nextBits hold the turn being computed by the worker pool.
+	next [][]uint8
+	nextBits [][]uint64
+	pool *workerPool
+	halo *haloWorkers
+	engine Engine
+	// life and root hold the HashLife universe and the node for the field when using HashLifeEngine.
+	life *hashLife
+	root *node
+	// remote holds the worker processes registered with the broker when running distributed.
+	remote *remoteWorkers
+	// remoteVersion is the version of remote that the world was last split between.
+	remoteVersion int
+	// broker keeps track of the completed turns and alive cells for the ticker. There is one per run,
+	// so that a run that is abandoned by its controller cannot interfere with the next one.
+	broker *Broker
+	// startTurn is the number of turns completed when the world was loaded, which is not 0 when resuming from a checkpoint.
+	startTurn int
+	checkpointEvery int
+	// name is the name of the input the world was loaded from, and outputTemplate the template for the names of its images.
+	name string
+	outputTemplate string
 }
 
 type Region struct {
     field [][]uint8
     start, end, height, width int
+    rule Rule
 }
 
 type Broker struct {
     stopCh                  chan bool
-    setCompletedTurnsCh     chan bool
+    setCompletedTurnsCh     chan int
     getCompletedTurnsCh     chan int
     resetCompletedTurnsCh   chan bool
     setCellsCountCh         chan int
@@ -43,7 +73,7 @@ type Broker struct {
 func NewBroker() *Broker {
     return &Broker{
         stopCh:                 make(chan bool),
-        setCompletedTurnsCh:    make(chan bool),
+        setCompletedTurnsCh:    make(chan int),
         getCompletedTurnsCh:    make(chan int),
         resetCompletedTurnsCh:  make(chan bool),
         setCellsCountCh:        make(chan int),
@@ -58,8 +88,8 @@ func (b *Broker) Start() {
         select {
         	case <-b.stopCh:
     			return
-    		case <- b.setCompletedTurnsCh:
-			completedTurns++
+    		case n := <- b.setCompletedTurnsCh:
+			completedTurns += n
 		case b.getCompletedTurnsCh <- completedTurns:
 		case  <- b.resetCompletedTurnsCh:
 			completedTurns = 0
@@ -80,7 +110,11 @@ func (b *Broker) GetCompletedTurns() int {
 }
 
 func (b *Broker) SetCompletedTurns() {
-    b.setCompletedTurnsCh <- true
+    b.AddCompletedTurns(1)
+}
+
+func (b *Broker) AddCompletedTurns(n int) {
+    b.setCompletedTurnsCh <- n
 }
 
 func (b *Broker) ResetCompletedTurns() {
@@ -104,16 +138,25 @@ func newField(height, width int) [][]uint8 {
     return field
 }
 
+// outputName returns the name of the image of the world after the given number of turns.
+func (world *World) outputName(turn int) string {
+    name, err := expandTemplate(world.outputTemplate, outputValues(world.name, world.width, world.height, turn))
+    util.Check(err)
+    return name
+}
+
 func (world *World) saveWorld(turn int, c distributorChannels) {
-    filename := strconv.Itoa(world.width) + "x" + strconv.Itoa(world.height) + "x" + strconv.Itoa(turn)
+    world.collect()
+    filename := world.outputName(turn)
 
     c.ioCommand <- ioOutput
     c.ioFilename <- filename
 
+    // The rows are sent one at a time as copies, as the world carries on while the io goroutine writes them.
     for y := 0; y < world.height; y++ {
-        for x := 0; x < world.width; x++ {
-            c.ioOutput <- world.field[y][x]
-        }
+        row := make([]uint8, world.width)
+        copy(row, world.field[y])
+        c.ioOutput <- row
     };
 
     c.events <- ImageOutputComplete{
@@ -122,7 +165,37 @@ func (world *World) saveWorld(turn int, c distributorChannels) {
     }
 }
 
+// saveCheckpoint writes a checkpoint of the world after the given number of turns.
+func (world *World) saveCheckpoint(turn int, c distributorChannels) {
+    world.collect()
+    filename := withExtension(world.outputName(turn), ".checkpoint")
+
+    // The io goroutine writes the checkpoint while the world carries on, so it is sent a copy of the field.
+    field := newField(world.height, world.width)
+    for y := range field {
+        copy(field[y], world.field[y])
+    }
+
+    c.ioCommand <- ioCheckpoint
+    c.ioFilename <- filename
+    c.ioCheckpointOutput <- checkpoint{
+        Name: world.name,
+        Width: world.width,
+        Height: world.height,
+        Turn: turn,
+        Rule: world.rule,
+        Topology: world.topology,
+        Field: field,
+    }
+
+    c.events <- CheckpointComplete{
+        CompletedTurns: turn,
+        Filename: filename,
+    }
+}
+
 func (world *World) getAlive() []util.Cell {
+    world.collect()
     alive := []util.Cell{}
     for y := 0; y < world.height; y++ {
         for x := 0; x < world.width; x++ {
@@ -134,67 +207,87 @@ func (world *World) getAlive() []util.Cell {
     return alive
 }
 
+// countAlive returns the number of alive cells, without collecting the world from the strip workers.
+func (world *World) countAlive() int {
+    if world.halo != nil {
+        return world.halo.alive
+    }
+    return len(world.getAlive())
+}
+
 func (region *Region) updateRegion(regionCh chan<- [][]uint8, flippedCh chan<- []util.Cell) {
+    field, flipped := region.next()
+    regionCh <- field
+    flippedCh <- flipped
+}
+
+// next computes the next turn of the region, returning its rows and the cells that flipped.
+func (region *Region) next() ([][]uint8, []util.Cell) {
     field := newField(region.height, region.width)
     haloOffset := 1
     flipped := []util.Cell{}
     for y := haloOffset; y < region.height + haloOffset; y++ {
-        for x := 0; x < region.width; x++ {
+        for x := haloOffset; x < region.width + haloOffset; x++ {
             currentCell := region.field[y][x]
-            nextCell := currentCell
         	aliveNeighbours := 0;
             for i := -1; i <= 1; i++ {
                 for j := -1; j <= 1; j++ {
-                    wx := x + i
-                    wy := y + j
-                    wx += region.width
-                    wx %= region.width
-                    if (j != 0 || i != 0) && region.field[wy][wx] == 255 {
+                    if (j != 0 || i != 0) && region.field[y+j][x+i] == 255 {
                         aliveNeighbours++
                     }
                 }
             }
-            if (aliveNeighbours < 2) || (aliveNeighbours > 3) {
-                nextCell = 0
-        	}
-            if aliveNeighbours == 3 {
-                nextCell = 255
-            }
+            nextCell := region.rule.next(currentCell, aliveNeighbours)
             if nextCell != currentCell {
                 flipped = append(flipped, util.Cell{
-                    X: x,
+                    X: x - haloOffset,
                     Y: y - haloOffset + region.start,
                 })
             }
-            field[y-haloOffset][x] = nextCell
+            field[y-haloOffset][x-haloOffset] = nextCell
         }
     }
-    regionCh <- field
-    flippedCh <- flipped
+    return field, flipped
 }
 
-func (world *World) makeHalo(w int) Region {
-     field := newField(0, 0)
-
-     regionHeight := world.height/world.threads
-     start := w * regionHeight
-     end := (w + 1) * regionHeight
-     if w == world.threads - 1 {
-         end = world.height
-     }
-     regionHeight = end - start
+// cell returns the value of the cell at (x, y), which may lie just outside the world.
+// Such cells are mapped back onto the world according to its topology, or are dead beyond an edge that does not wrap.
+func (world *World) cell(x, y int) uint8 {
+    x, y, ok := world.topology.wrap(x, y, world.width, world.height)
+    if !ok {
+        return 0
+    }
+    return world.field[y][x]
+}
 
-     downRowPtr := end % world.height
+// regionBounds returns the first row of worker w's region and the row just after it.
+func (world *World) regionBounds(w int) (int, int) {
+     return splitRows(world.height, world.threads, w)
+}
 
-     upRowPtr := start - 1
-     upRowPtr = upRowPtr + world.height
-     upRowPtr = upRowPtr % world.height
+func (world *World) makeHalo(w int) Region {
+     start, end := world.regionBounds(w)
+     return world.makeRegion(start, end)
+}
 
-     field = append(field, world.field[upRowPtr])
-     for row := start; row < end; row++ {
-        field = append(field, world.field[row])
+// makeRegion returns rows [start, end) of the world surrounded by their halo.
+func (world *World) makeRegion(start, end int) Region {
+     regionHeight := end - start
+
+     // The region is surrounded by a halo of one cell on every side.
+     field := newField(regionHeight + 2, world.width + 2)
+     for y := start - 1; y <= end; y++ {
+        row := field[y - start + 1]
+        if y >= 0 && y < world.height {
+            copy(row[1:], world.field[y])
+        } else {
+            for x := 0; x < world.width; x++ {
+                row[x + 1] = world.cell(x, y)
+            }
+        }
+        row[0] = world.cell(-1, y)
+        row[world.width + 1] = world.cell(world.width, y)
      }
-     field = append(field, world.field[downRowPtr])
 
      return Region{
          field: field,
@@ -202,10 +295,28 @@ func (world *World) makeHalo(w int) Region {
          end: end,
          height: regionHeight,
          width: world.width,
+         rule: world.rule,
      }
 }
 
 func (world *World) updateWorld(turn int, c distributorChannels) {
+    if world.remote != nil {
+        world.updateRemoteWorld(turn, c)
+        return
+    }
+    if world.halo != nil {
+        world.updateHaloWorld(turn, c)
+        return
+    }
+    if world.pool != nil {
+        world.updatePooledWorld(turn, c)
+        return
+    }
+    if world.backend == BitBackend {
+        world.updatePackedWorld(turn, c)
+        return
+    }
+
     var newFieldData [][]uint8
 
     regionCh := make([]chan [][]uint8, world.threads);
@@ -229,6 +340,7 @@ func (world *World) updateWorld(turn int, c distributorChannels) {
             c.events <- CellFlipped{
                 CompletedTurns: turn,
                 Cell: flipped[f],
+                Value: newFieldData[flipped[f].Y][flipped[f].X],
             }
         }
     }
@@ -236,26 +348,86 @@ func (world *World) updateWorld(turn int, c distributorChannels) {
     world.field = newFieldData
 }
 
-func loadWorld(height, width, threads int, c distributorChannels) *World {
-    inFilename := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+// loadWorld loads the world from its input, returning the error of the io goroutine if it cannot be read.
+func loadWorld(p Params, c distributorChannels) (*World, error) {
+    if p.Resume != "" {
+        return resumeWorld(p, c)
+    }
+    height, width := p.ImageHeight, p.ImageWidth
+    name := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+    inFilename := "images/" + name + ".pgm"
+    if p.InputPath != "" {
+        inFilename = p.InputPath
+        name = inputName(p.InputPath)
+    }
 
     c.ioCommand <- ioInput;
 
     c.ioFilename <- inFilename;
 
-    field := newField(height, width)
+    field := make([][]uint8, height)
     for y := 0; y < height; y++ {
-        for x := 0; x < width; x++ {
-            p := <-c.ioInput;
-            field[y][x] = p
-            if p == 255 {c.events <- CellFlipped{0, util.Cell{X: x, Y: y}}}
+        select {
+        case field[y] = <-c.ioInput:
+        case err := <-c.ioErrors:
+            return nil, err
+        }
+        for x, value := range field[y] {
+            if value != 0 {c.events <- CellFlipped{0, util.Cell{X: x, Y: y}, value}}
         }
     };
 
-    return &World{field: field, height: height, width: width, threads: threads, }
+    return newWorld(p, name, field, p.Rule, p.Topology, 0), nil
+}
+
+// resumeWorld loads the world from the checkpoint at p.Resume, with the rule and topology it was written with.
+func resumeWorld(p Params, c distributorChannels) (*World, error) {
+    c.ioCommand <- ioResume
+    c.ioFilename <- p.Resume
+    var cp checkpoint
+    select {
+    case cp = <-c.ioCheckpointInput:
+    case err := <-c.ioErrors:
+        return nil, err
+    }
+
+    for y := 0; y < cp.Height; y++ {
+        for x := 0; x < cp.Width; x++ {
+            if value := cp.Field[y][x]; value != 0 {
+                c.events <- CellFlipped{cp.Turn, util.Cell{X: x, Y: y}, value}
+            }
+        }
+    }
+
+    return newWorld(p, cp.Name, cp.Field, cp.Rule, cp.Topology, cp.Turn), nil
+}
+
+// newWorld makes the world for a field loaded from the named input after the given number of turns,
+// choosing the backend and engine from p.
+func newWorld(p Params, name string, field [][]uint8, rule Rule, topology Topology, turn int) *World {
+    world := &World{
+        name: name,
+        outputTemplate: p.outputTemplate(),
+        field: field,
+        height: len(field),
+        width: len(field[0]),
+        threads: p.Threads,
+        rule: rule.orDefault(),
+        topology: topology,
+        startTurn: turn,
+        checkpointEvery: p.CheckpointEvery,
+    }
+    if p.Backend == BitBackend && world.rule.States <= 2 {
+        world.backend = BitBackend
+        world.packField()
+    }
+    if p.Engine == HashLifeEngine && world.hashLifeSupported() {
+        world.engine = HashLifeEngine
+    }
+    return world
 }
 
-func reportAlive(stopReporterCh <-chan bool, c distributorChannels) {
+func reportAlive(b *Broker, stopReporterCh <-chan bool, c distributorChannels) {
     ticker := time.NewTicker(2 * time.Second)
 
     for {
@@ -275,19 +447,22 @@ func reportAlive(stopReporterCh <-chan bool, c distributorChannels) {
     }
 }
 
-func (world *World) liveWorld(turns int, wg *sync.WaitGroup, c distributorChannels) {
-    defer wg.Done()
+// liveWorld runs the turns of the world until they are done, 'q' or 'k' is pressed or ctx is cancelled,
+// returning the first error of the io goroutine if there is one.
+func (world *World) liveWorld(ctx context.Context, turns int, c distributorChannels) error {
+    b := world.broker
     paused := false
-    turn := 0
+    turn := world.startTurn
     for turn < turns {
         select {
             case cmd := <- c.keyPresses:
                 switch cmd {
                 case 's':
                     world.saveWorld(turn, c)
-                case 'q':
+                    world.saveCheckpoint(turn, c)
+                case 'q', 'k':
                     world.saveWorld(turn, c)
-                    return;
+                    return nil
                 case 'p':
                     if paused {
                         c.events <- StateChange{
@@ -306,42 +481,102 @@ func (world *World) liveWorld(turns int, wg *sync.WaitGroup, c distributorChanne
                 default:
                     paused = false
                 }
+            case err := <-c.ioErrors:
+                return err
+            case <-ctx.Done():
+                return nil
                 default:
                     if !paused {
-                        world.updateWorld(turn, c);
-                        b.SetCellsCount(len(world.getAlive()))
-                        b.SetCompletedTurns()
+                        completed := 1
+                        if world.engine == HashLifeEngine {
+                            completed = world.jumpWorld(turns - turn, turn, c)
+                        } else {
+                            world.updateWorld(turn, c);
+                        }
+                        b.SetCellsCount(world.countAlive())
+                        b.AddCompletedTurns(completed)
                         c.events <- TurnComplete{
-                            CompletedTurns: turn,
+                            CompletedTurns: turn + completed - 1,
+                        }
+                        turn += completed
+                        if world.checkpointEvery > 0 && turn / world.checkpointEvery > (turn - completed) / world.checkpointEvery {
+                            world.saveCheckpoint(turn, c)
                         }
-                        turn++;
                     }
         }
     }
+    return nil
+}
+
+// fail reports an error that stops the simulation after the given number of turns and closes the events channel.
+func fail(turn int, err error, events chan<- Event) error {
+    events <- Error{
+        CompletedTurns: turn,
+        Err: err,
+    }
+    close(events)
+    return err
 }
 
-func distributor(p Params, c distributorChannels) {
-    world := loadWorld(p.ImageHeight, p.ImageWidth, p.Threads, c)
+// distributor runs the simulation, returning ctx.Err() if it was stopped by ctx.
+// The io goroutine is stopped when it returns.
+func distributor(ctx context.Context, p Params, c distributorChannels) error {
+    defer close(c.ioCommand)
+
+    world, err := loadWorld(p, c)
+    if err != nil {
+        return fail(0, err, c.events)
+    }
+    if world.engine == StepEngine {
+        switch {
+        case p.remote != nil:
+            world.startRemote(p.remote)
+        case p.Workers == HaloWorkers && world.topology != CrossSurface:
+            world.startHaloWorkers(p.Tiles)
+        case p.Workers != SpawnWorkers:
+            world.startPool()
+        }
+    }
+
+    b := NewBroker()
+    world.broker = b
 
     var stopReporterCh = make(chan bool)
-    go reportAlive(stopReporterCh, c)
+    go reportAlive(b, stopReporterCh, c)
 
     go b.Start()
+    if world.startTurn > 0 {
+        b.AddCompletedTurns(world.startTurn)
+    }
 
     var wg sync.WaitGroup
 
     wg.Add(1);
 
-    go world.liveWorld(p.Turns, &wg, c)
+    go func() {
+        defer wg.Done()
+        err = world.liveWorld(ctx, p.Turns, c)
+    }()
 
     wg.Wait()
 
+    if world.pool != nil {
+        world.stopPool()
+    }
+    if world.halo != nil {
+        world.stopHaloWorkers()
+    }
+
     stopReporterCh <- true
 
     completedTurns := b.GetCompletedTurns()
 
     b.Stop()
 
+    if err != nil {
+        return fail(completedTurns, err, c.events)
+    }
+
     c.events <- FinalTurnComplete{
         CompletedTurns: completedTurns,
         Alive: world.getAlive(),
@@ -353,6 +588,12 @@ func distributor(p Params, c distributorChannels) {
     c.ioCommand <- ioCheckIdle
     <-c.ioIdle;
 
+    select {
+    case err := <-c.ioErrors:
+        return fail(completedTurns, err, c.events)
+    default:
+    }
+
     c.events <- StateChange{
         CompletedTurns: completedTurns,
         NewState: Quitting,
@@ -360,4 +601,5 @@ func distributor(p Params, c distributorChannels) {
 
     // Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
     close(c.events)
+    return ctx.Err()
 }
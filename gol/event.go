@@ -28,6 +28,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// CheckpointComplete is an Event notifying the user that a checkpoint has been handed to the io goroutine.
+// This Event should be sent every time a checkpoint is saved.
+type CheckpointComplete struct { // implements Event
+	CompletedTurns int
+	Filename       string
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -47,9 +54,12 @@ type StateChange struct { // implements Event
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
+// Value is the new grey level of the cell: 255 when alive, 0 when dead and
+// in between for the dying states of Generations rules.
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
+	Value          uint8
 }
 
 // TurnComplete is an Event notifying the GUI about turn completion.
@@ -67,6 +77,30 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// TilesSkipped is an Event reporting how many of the halo workers' tiles were skipped on a turn
+// because neither they nor their neighbourhood changed on the turn before.
+// It is sent before TurnComplete on every turn computed by the halo workers.
+type TilesSkipped struct { // implements Event
+	CompletedTurns int
+	Tiles          int
+	Skipped        int
+}
+
+// WorkersChanged is an Event reporting the number of worker processes a distributed simulation is split between.
+// It is sent before the first turn, and before any turn on which workers have joined or left since the turn before.
+type WorkersChanged struct { // implements Event
+	CompletedTurns int
+	Workers        int
+}
+
+// Error is an Event reporting that the simulation could not carry on, such as when its input cannot be read
+// or an image cannot be written. It is the last Event before the channel is closed, and Err is also returned by Run.
+// It is not sent when the context of RunContext is cancelled, which finishes the simulation normally.
+type Error struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -106,6 +140,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CheckpointComplete) String() string {
+	return fmt.Sprintf("Checkpoint %v output complete", event.Filename)
+}
+
+func (event CheckpointComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return fmt.Sprintf("")
 }
@@ -122,6 +164,30 @@ func (event TurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event TilesSkipped) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event TilesSkipped) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event WorkersChanged) String() string {
+	return fmt.Sprintf("Workers %v", event.Workers)
+}
+
+func (event WorkersChanged) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event Error) String() string {
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event Error) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event FinalTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
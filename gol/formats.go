@@ -0,0 +1,252 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// patternFormat reads and writes the worlds and patterns of one file format.
+// Formats that cannot hold the dying states of Generations rules save those cells as dead.
+type patternFormat struct {
+	read  func(io.Reader) (pattern, error)
+	write func(io.Writer, [][]uint8, Rule) error
+}
+
+// pgmFormat is the format of files whose extension is not in patternFormats.
+// It reads PBM and PGM images, and writes PGM images.
+var pgmFormat = patternFormat{readPnm, writePgm}
+
+// patternFormats are the pattern formats, by file extension:
+// RLE, plaintext as used by LifeWiki, Life 1.06 lists of alive cells and Golly's macrocell quadtrees.
+var patternFormats = map[string]patternFormat{
+	".rle":   {readRLE, writeRLE},
+	".cells": {readCells, writeCells},
+	".lif":   {readLife106, writeLife106},
+	".life":  {readLife106, writeLife106},
+	".mc":    {readMacrocell, writeMacrocell},
+}
+
+// maxPatternCells is the largest number of cells of a pattern or image read from a file, such as 8192x8192,
+// so that a header giving a huge size fails with an error rather than running out of memory. Every row of
+// a world is a slice of its own, so the limit is on cells rather than on bytes.
+const maxPatternCells = 1 << 26
+
+// checkPatternSize returns an error if a width x height pattern is larger than maxPatternCells.
+func checkPatternSize(width, height int) error {
+	if width > maxPatternCells || height > maxPatternCells || width*height > maxPatternCells {
+		return fmt.Errorf("%vx%v pattern is larger than %v cells", width, height, maxPatternCells)
+	}
+	return nil
+}
+
+// isPattern reports whether the file at path is a pattern rather than a pgm image, from its extension.
+func isPattern(path string) bool {
+	_, ok := patternFormats[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// formatOf returns the format of the file at path, from its extension. PNG images have a scale of 1 and the grey palette.
+func formatOf(path string) patternFormat {
+	if format, ok := patternFormats[strings.ToLower(filepath.Ext(path))]; ok {
+		return format
+	}
+	if isPng(path) {
+		return pngFormat(1, Palette{})
+	}
+	return pgmFormat
+}
+
+// fileFormat returns the format of the file at path, from its extension, with the scale and palette of p for PNG images.
+func (p Params) fileFormat(path string) patternFormat {
+	if isPng(path) {
+		return pngFormat(p.PngScale, p.Palette)
+	}
+	return formatOf(path)
+}
+
+// Convert converts the image or pattern at inPath to the format of outPath, chosen by its extension.
+// The cells of PNG images are alive when nearer to white than to black.
+func Convert(inPath, outPath string) error {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	p, err := formatOf(inPath).read(in)
+	if err != nil {
+		return fmt.Errorf("reading %v: %v", inPath, err)
+	}
+	if isPng(inPath) {
+		p.threshold(pngThreshold)
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := formatOf(outPath).write(out, p.Cells, p.Rule); err != nil {
+		return fmt.Errorf("writing %v: %v", outPath, err)
+	}
+	return out.Close()
+}
+
+// writePgm writes the world as a binary pgm image.
+func writePgm(w io.Writer, cells [][]uint8, rule Rule) error {
+	height, width := len(cells), 0
+	if height > 0 {
+		width = len(cells[0])
+	}
+	if _, err := fmt.Fprintf(w, "P5\n%v %v\n255\n", width, height); err != nil {
+		return err
+	}
+	for _, row := range cells {
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCells reads a pattern in the plaintext format, where lines starting with '!' are comments
+// and every other line is a row of cells, '.' for dead and 'O' for alive. Rows may be shorter than the pattern.
+func readCells(r io.Reader) (pattern, error) {
+	var p pattern
+	var rows []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		rows = append(rows, line)
+		if len(line) > p.Width {
+			p.Width = len(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	p.Height = len(rows)
+	if err := checkPatternSize(p.Width, p.Height); err != nil {
+		return p, err
+	}
+	p.Cells = newField(p.Height, p.Width)
+	for y, row := range rows {
+		for x, c := range row {
+			switch c {
+			case '.':
+			case 'O', '*':
+				p.Cells[y][x] = 255
+			default:
+				return p, fmt.Errorf("invalid character %q in plaintext pattern", c)
+			}
+		}
+	}
+	return p, nil
+}
+
+// writeCells writes the world in the plaintext format. Every row is written in full, so that the size of the world is kept.
+func writeCells(w io.Writer, cells [][]uint8, rule Rule) error {
+	out := bufio.NewWriter(w)
+	for _, row := range cells {
+		for _, cell := range row {
+			if cell == 255 {
+				out.WriteByte('O')
+			} else {
+				out.WriteByte('.')
+			}
+		}
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// life106Header is the first line of a pattern in the Life 1.06 format.
+const life106Header = "#Life 1.06"
+
+// readLife106 reads a pattern in the Life 1.06 format, which lists the x and y coordinates of each alive cell on a line.
+// The coordinates may be negative; the pattern is the smallest rectangle holding every cell.
+func readLife106(r io.Reader) (pattern, error) {
+	var p pattern
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != life106Header {
+		return p, errors.New("not a Life 1.06 pattern")
+	}
+	var xs, ys []int
+	var left, top, right, bottom int
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return p, fmt.Errorf("invalid Life 1.06 line %q", line)
+		}
+		x, errX := strconv.Atoi(fields[0])
+		y, errY := strconv.Atoi(fields[1])
+		if errX != nil || errY != nil {
+			return p, fmt.Errorf("invalid Life 1.06 line %q", line)
+		}
+		if len(xs) == 0 {
+			left, top, right, bottom = x, y, x, y
+		}
+		xs, ys = append(xs, x), append(ys, y)
+		left, right = minInt(left, x), maxInt(right, x)
+		top, bottom = minInt(top, y), maxInt(bottom, y)
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	if len(xs) == 0 {
+		p.Cells = newField(0, 0)
+		return p, nil
+	}
+	// The spans are found as unsigned differences, which cannot overflow.
+	if uint64(right)-uint64(left) >= maxPatternCells || uint64(bottom)-uint64(top) >= maxPatternCells {
+		return p, fmt.Errorf("Life 1.06 pattern spans more than %v cells", maxPatternCells)
+	}
+	p.Width, p.Height = right-left+1, bottom-top+1
+	if err := checkPatternSize(p.Width, p.Height); err != nil {
+		return p, err
+	}
+	p.Cells = newField(p.Height, p.Width)
+	for i := range xs {
+		p.Cells[ys[i]-top][xs[i]-left] = 255
+	}
+	return p, nil
+}
+
+// writeLife106 writes the alive cells of the world in the Life 1.06 format, with the top left cell at 0 0.
+func writeLife106(w io.Writer, cells [][]uint8, rule Rule) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, life106Header)
+	for y, row := range cells {
+		for x, cell := range row {
+			if cell == 255 {
+				fmt.Fprintln(out, x, y)
+			}
+		}
+	}
+	return out.Flush()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package gol
+
+import (
+	"fmt"
+	"image/gif"
+	"os"
+	"time"
+)
+
+// maxGifPixels is the largest number of pixels in all the frames of a GIF, which are held in memory until the end of the run.
+const maxGifPixels = 1 << 28
+
+// GifOptions chooses the turns recorded in an animated GIF and how they are drawn.
+type GifOptions struct {
+	// Path is the file the GIF is written to.
+	Path string
+	// From and To are the first and last completed turns recorded. To is the end of the run when it is 0.
+	From, To int
+	// Stride is the number of turns between frames. Defaults to 1.
+	Stride int
+	// Scale is the size in pixels of the square each cell is drawn as. Defaults to 1.
+	Scale int
+	// Delay is the time each frame is shown for, which GIF rounds to hundredths of a second.
+	Delay time.Duration
+	// Palette gives the colours of dead and alive cells.
+	Palette Palette
+}
+
+// RecordGif forwards the events of a run of a width x height world from in to out, and draws the world
+// from the CellFlipped events into a frame on each TurnComplete in the range of options. Frames are taken
+// after turns, so the world as loaded is only drawn when a run has no turns. When in is closed, out is
+// closed and the frames are written to options.Path as an animated GIF, or an error is returned without
+// writing the file if there are none. Once the frames hold maxGifPixels
+// pixels no more are recorded, and an error is returned after the frames so far have been written.
+func RecordGif(options GifOptions, width, height int, in <-chan Event, out chan<- Event) error {
+	if options.Stride < 1 {
+		options.Stride = 1
+	}
+	if options.Scale < 1 {
+		options.Scale = 1
+	}
+	delay := int((options.Delay + 5*time.Millisecond) / (10 * time.Millisecond))
+	field := newField(height, width)
+	animation := gif.GIF{}
+	last := -1
+	pixels := 0
+	var full error
+
+	record := func(turn int) {
+		if turn < options.From || options.To > 0 && turn > options.To || last >= 0 && turn-last < options.Stride || full != nil {
+			return
+		}
+		pixels += width * height * options.Scale * options.Scale
+		if pixels > maxGifPixels {
+			full = fmt.Errorf("%v was cut short after %v frames, as more would not fit in memory", options.Path, len(animation.Image))
+			return
+		}
+		frame := scaledImage(field, options.Scale, options.Palette)
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, delay)
+		last = turn
+	}
+
+	for event := range in {
+		switch e := event.(type) {
+		case CellFlipped:
+			field[e.Cell.Y][e.Cell.X] = e.Value
+		case TurnComplete:
+			record(e.CompletedTurns + 1)
+		case FinalTurnComplete:
+			if len(animation.Image) == 0 {
+				record(e.CompletedTurns)
+			}
+		}
+		out <- event
+	}
+	close(out)
+	if len(animation.Image) == 0 {
+		if full != nil {
+			return full
+		}
+		to := "the end of the run"
+		if options.To > 0 {
+			to = fmt.Sprintf("turn %v", options.To)
+		}
+		return fmt.Errorf("%v was not written, as the run completed no turns from turn %v to %v", options.Path, options.From, to)
+	}
+
+	file, err := os.Create(options.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := gif.EncodeAll(file, &animation); err != nil {
+		return fmt.Errorf("writing %v: %v", options.Path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return full
+}
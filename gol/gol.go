@@ -1,23 +1,97 @@
 package gol
 
+import "context"
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        Rule
+	Topology    Topology
+	Backend     Backend
+	Engine      Engine
+	Workers     WorkerMode
+	Tiles       Grid
+	// InputPath is the path of the pbm, pgm or png image or pattern to load instead of images/<ImageWidth>x<ImageHeight>.pgm.
+	// The size of an image is taken from its header. Patterns, which are RLE (.rle), plaintext (.cells),
+	// Life 1.06 (.lif or .life) or macrocell (.mc) files, are centred in the world, which is the size of the pattern
+	// if ImageWidth and ImageHeight are 0. Macrocell patterns on a bounded grid give the size of the world instead.
+	// RLE and macrocell patterns give the rule if Rule is the zero Rule.
+	InputPath string
+	// Threshold makes cells of the input whose grey level, scaled to 0-255, is at least Threshold alive,
+	// and all other cells dead. When it is 0 grey levels are kept, which keeps the dying states of Generations rules,
+	// except for png images whose cells are alive when nearer to the alive colour than the dead colour.
+	Threshold int
+	// OutputDir is the directory images and checkpoints are written to, and is created if needed. Defaults to out.
+	OutputDir string
+	// OutputTemplate is the template for the names of the images, such as "{name}-{turn:08d}.pgm".
+	// {name} is the name of the input image without its extension, and {width}, {height} and {turn} are
+	// the size of the world and the number of completed turns. A placeholder can be followed by a colon and
+	// a fmt verb without the %. Checkpoints have the same name with the extension .checkpoint.
+	// The extension of the name chooses the format: one of the pattern formats of InputPath, a png image for .png,
+	// or a pgm image otherwise.
+	// Defaults to "{width}x{height}x{turn}" with the extension of the input, or .pgm.
+	OutputTemplate string
+	// PngScale is the size in pixels of the square each cell is drawn as in png images, and read back from. Defaults to 1.
+	PngScale int
+	// Palette gives the colours of dead and alive cells in png images. The zero Palette is black and white.
+	Palette Palette
+	// AtomicOutput makes images and checkpoints be written to a temporary file that is renamed once complete,
+	// so that a file in OutputDir is never seen half written.
+	AtomicOutput bool
+	// CheckpointEvery makes the simulation write a checkpoint to OutputDir every CheckpointEvery turns.
+	// Checkpoints are also written when 's' is pressed.
+	CheckpointEvery int
+	// Resume is the path of a checkpoint to carry on from instead of loading the image from images/.
+	// The simulation continues with the rule and topology of the checkpoint, up to Turns turns in total.
+	Resume string
+	// Broker is the address of a broker to run the simulation on, such as localhost:8030.
+	// When it is empty the simulation runs in this process.
+	Broker string
+	// Attach makes Run attach to the simulation already running on the broker instead of starting a new one.
+	// ImageWidth and ImageHeight must match that simulation; the other parameters are ignored.
+	Attach bool
+
+	// remote is set by a broker for the runs it starts, so that they use its worker processes.
+	remote *remoteWorkers
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
-func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+// It returns once the simulation has finished and events has been closed. If the simulation cannot carry on,
+// such as when its input cannot be read, an Error event is sent before events is closed and the error is returned.
+func Run(p Params, events chan<- Event, keyPresses <-chan rune) error {
+	return RunContext(context.Background(), p, events, keyPresses)
+}
+
+// RunContext is Run, except that the simulation stops when ctx is cancelled or its deadline passes.
+// It then finishes as if 'q' had been pressed, sending the final events and saving the final image
+// before closing events, and returns ctx.Err(). Events must be received until the channel is closed.
+// A simulation on a broker is stopped on the broker as well.
+func RunContext(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune) error {
+	if p.Broker != "" {
+		return runController(ctx, p, events, keyPresses)
+	}
+
+	p, err := p.withInput()
+	if err == nil {
+		_, err = expandTemplate(p.outputTemplate(), outputValues("name", p.ImageWidth, p.ImageHeight, 0))
+	}
+	if err != nil {
+		return fail(0, err, events)
+	}
 
 	//	TODO: Put the missing channels in here.
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
 	ioFileName := make(chan string)
-	ioOutput := make(chan uint8)
-	ioInput := make(chan uint8)
+	ioOutput := make(chan []uint8)
+	ioInput := make(chan []uint8)
+	ioCheckpointOutput := make(chan checkpoint)
+	ioCheckpointInput := make(chan checkpoint)
+	ioErrors := make(chan error, 1)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
@@ -25,17 +99,24 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: ioFileName,
 		output:   ioOutput,
 		input:    ioInput,
+
+		checkpointOutput: ioCheckpointOutput,
+		checkpointInput:  ioCheckpointInput,
+		errors:           ioErrors,
 	}
 	go startIo(p, ioChannels)
 
 	distributorChannels := distributorChannels{
-		events:     events,
-		ioCommand:  ioCommand,
-		ioIdle:     ioIdle,
-		ioFilename: ioFileName,
-		ioOutput:   ioOutput,
-		ioInput:    ioInput,
+		events:             events,
+		ioCommand:          ioCommand,
+		ioIdle:             ioIdle,
+		ioFilename:         ioFileName,
+		ioOutput:           ioOutput,
+		ioInput:            ioInput,
+		ioCheckpointOutput: ioCheckpointOutput,
+		ioCheckpointInput:  ioCheckpointInput,
+		ioErrors:           ioErrors,
         keyPresses: keyPresses,
 	}
-	distributor(p, distributorChannels)
+	return distributor(ctx, p, distributorChannels)
 }
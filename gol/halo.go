@@ -0,0 +1,535 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Grid is a layout of tiles, Columns wide and Rows high, that the halo workers split the world into.
+// The zero Grid chooses a layout automatically from the size of the world and the number of threads.
+type Grid struct {
+	Columns, Rows int
+}
+
+// String returns the grid in the form accepted by Set.
+func (grid Grid) String() string {
+	if grid == (Grid{}) {
+		return "auto"
+	}
+	return fmt.Sprintf("%dx%d", grid.Columns, grid.Rows)
+}
+
+// Set parses a grid written as columns x rows, e.g. 4x4, or auto, so that a Grid can be used as a flag.Value.
+func (grid *Grid) Set(s string) error {
+	if s == "auto" {
+		*grid = Grid{}
+		return nil
+	}
+	var parsed Grid
+	var rest string
+	n, _ := fmt.Sscanf(s+" ", "%dx%d%s", &parsed.Columns, &parsed.Rows, &rest)
+	if n != 2 || parsed.Columns < 1 || parsed.Rows < 1 {
+		return errors.New("invalid grid " + s + ", expected columns x rows such as 4x4")
+	}
+	*grid = parsed
+	return nil
+}
+
+// tileCommand is sent by the distributor to a tile worker.
+type tileCommand uint8
+
+const (
+	// tileTurn asks the worker to exchange halos with its neighbours and compute the next turn.
+	tileTurn tileCommand = iota
+	// tileCollect asks the worker to send a copy of its cells to the distributor.
+	tileCollect
+)
+
+// haloRow is a boundary row sent from one tile worker to the tile above or below it,
+// including the halo cells on either side of it. Only one of cells and packed is used, depending on the backend.
+type haloRow struct {
+	cells  []uint8
+	packed packedRow
+}
+
+// tileReport is sent by a tile worker to the distributor after every turn.
+// Its slices are reused by the worker on the following turn.
+type tileReport struct {
+	flipped []util.Cell
+	values  []uint8
+	alive   int
+	skipped bool
+}
+
+// tileState is a copy of the cells of a tile, sent to the distributor when it collects the world.
+type tileState struct {
+	top, left int
+	cells     [][]uint8
+}
+
+// haloWorkers holds the distributor's channels to the tile workers.
+type haloWorkers struct {
+	commands []chan tileCommand
+	reports  []chan tileReport
+	states   []chan tileState
+	// alive is the number of alive cells after the last turn and stale whether world.field is out of date.
+	alive int
+	stale bool
+}
+
+// tile is a worker that owns a rectangle of the world for the whole run.
+type tile struct {
+	top, left, height, width int
+	rule                     Rule
+	backend                  Backend
+
+	// rows and next hold the current and next turn of the tile, surrounded by a halo of one cell on every side.
+	// With BitBackend the halo cells on either side of a row are the left and right bits of the packed row.
+	rows, next         [][]uint8
+	packed, nextPacked []packedRow
+
+	// toLeft and toRight send the tile's outer columns to the neighbouring tiles, and toUp and toDown its outer rows.
+	// They are nil where the world has a dead edge.
+	toLeft, toRight     chan<- []uint8
+	fromLeft, fromRight <-chan []uint8
+	toUp, toDown        chan<- haloRow
+	fromUp, fromDown    <-chan haloRow
+	// mirrorUp and mirrorDown are set where rows arriving across the edge of the world must be mirrored.
+	mirrorUp, mirrorDown bool
+	// leftColumn and rightColumn hold the outer columns while they are sent.
+	leftColumn, rightColumn []uint8
+
+	// changed is set when the tile's cells changed on the last turn, and haloChanged when its halo cells
+	// changed in the last exchange. When neither is set the next turn is the same as the current one.
+	changed, haloChanged bool
+
+	report tileReport
+}
+
+// wrapsRows reports whether the top and bottom edges of the world are joined.
+func (topology Topology) wrapsRows() bool {
+	return topology == Torus || topology == KleinBottle
+}
+
+// splitRows returns the first row of part i when height rows are split into the given number of parts,
+// and the row just after it. It splits columns in the same way.
+func splitRows(height, parts, i int) (int, int) {
+	partHeight := height / parts
+	start := i * partHeight
+	end := (i + 1) * partHeight
+	if i == parts-1 {
+		end = height
+	}
+	return start, end
+}
+
+// chooseGrid returns the layout of tiles to use for the world.
+// A requested grid is only shrunk so that every tile is at least one cell in each direction.
+// Otherwise the layout uses as many of the threads as possible, exchanging as few halo cells as it can.
+// Rows arriving across the edge of a Klein bottle are mirrored, which only lines up with a single column of tiles.
+func (world *World) chooseGrid(requested Grid) Grid {
+	maxColumns := world.width
+	if world.topology == KleinBottle {
+		maxColumns = 1
+	}
+	if requested != (Grid{}) {
+		grid := requested
+		if grid.Columns > maxColumns {
+			grid.Columns = maxColumns
+		}
+		if grid.Rows > world.height {
+			grid.Rows = world.height
+		}
+		return grid
+	}
+	best, bestCost := Grid{Columns: 1, Rows: 1}, 0
+	for rows := 1; rows <= world.threads && rows <= world.height; rows++ {
+		for columns := 1; rows*columns <= world.threads && columns <= maxColumns; columns++ {
+			cost := (rows-1)*world.width + (columns-1)*world.height
+			tiles, bestTiles := rows*columns, best.Rows*best.Columns
+			if tiles > bestTiles || tiles == bestTiles && cost < bestCost {
+				best, bestCost = Grid{Columns: columns, Rows: rows}, cost
+			}
+		}
+	}
+	return best
+}
+
+// startHaloWorkers gives each worker its own tile of the world and connects it to its neighbours.
+func (world *World) startHaloWorkers(requested Grid) {
+	grid := world.chooseGrid(requested)
+	n := grid.Rows * grid.Columns
+	halo := &haloWorkers{
+		commands: make([]chan tileCommand, n),
+		reports:  make([]chan tileReport, n),
+		states:   make([]chan tileState, n),
+	}
+	// Each channel carries halo cells into tile i from the neighbour on the side it is named after.
+	// They are buffered so that a tile can send to itself when it is the only one in its row or column.
+	fromLeft := make([]chan []uint8, n)
+	fromRight := make([]chan []uint8, n)
+	fromUp := make([]chan haloRow, n)
+	fromDown := make([]chan haloRow, n)
+	for i := 0; i < n; i++ {
+		fromLeft[i] = make(chan []uint8, 1)
+		fromRight[i] = make(chan []uint8, 1)
+		fromUp[i] = make(chan haloRow, 1)
+		fromDown[i] = make(chan haloRow, 1)
+	}
+	index := func(row, column int) int {
+		return (row+grid.Rows)%grid.Rows*grid.Columns + (column+grid.Columns)%grid.Columns
+	}
+
+	for row := 0; row < grid.Rows; row++ {
+		top, bottom := splitRows(world.height, grid.Rows, row)
+		for column := 0; column < grid.Columns; column++ {
+			left, right := splitRows(world.width, grid.Columns, column)
+			i := index(row, column)
+			t := &tile{
+				top:         top,
+				left:        left,
+				height:      bottom - top,
+				width:       right - left,
+				rule:        world.rule,
+				backend:     world.backend,
+				leftColumn:  make([]uint8, bottom-top),
+				rightColumn: make([]uint8, bottom-top),
+				changed:     true,
+			}
+			if world.topology != Plane {
+				t.toLeft, t.fromLeft = fromRight[index(row, column-1)], fromLeft[i]
+				t.toRight, t.fromRight = fromLeft[index(row, column+1)], fromRight[i]
+			} else {
+				if column > 0 {
+					t.toLeft, t.fromLeft = fromRight[index(row, column-1)], fromLeft[i]
+				}
+				if column < grid.Columns-1 {
+					t.toRight, t.fromRight = fromLeft[index(row, column+1)], fromRight[i]
+				}
+			}
+			if row > 0 || world.topology.wrapsRows() {
+				t.toUp, t.fromUp = fromDown[index(row-1, column)], fromUp[i]
+				t.mirrorUp = row == 0 && world.topology == KleinBottle
+			}
+			if row < grid.Rows-1 || world.topology.wrapsRows() {
+				t.toDown, t.fromDown = fromUp[index(row+1, column)], fromDown[i]
+				t.mirrorDown = row == grid.Rows-1 && world.topology == KleinBottle
+			}
+			t.load(world.field)
+
+			halo.commands[i] = make(chan tileCommand)
+			halo.reports[i] = make(chan tileReport)
+			halo.states[i] = make(chan tileState)
+			go t.run(halo.commands[i], halo.reports[i], halo.states[i])
+		}
+	}
+	world.halo = halo
+}
+
+// stopHaloWorkers collects the final state of the world and stops the tile workers.
+func (world *World) stopHaloWorkers() {
+	world.collect()
+	for _, commands := range world.halo.commands {
+		close(commands)
+	}
+	world.halo = nil
+}
+
+// collect copies the cells of every tile into world.field if they have changed since the last collection.
+func (world *World) collect() {
+	if world.halo == nil || !world.halo.stale {
+		return
+	}
+	for i, commands := range world.halo.commands {
+		commands <- tileCollect
+		state := <-world.halo.states[i]
+		for y, row := range state.cells {
+			copy(world.field[state.top+y][state.left:], row)
+		}
+	}
+	world.halo.stale = false
+}
+
+// updateHaloWorld is the equivalent of updateWorld using the tile workers.
+func (world *World) updateHaloWorld(turn int, c distributorChannels) {
+	for _, commands := range world.halo.commands {
+		commands <- tileTurn
+	}
+	world.halo.alive = 0
+	skipped := 0
+	for _, reports := range world.halo.reports {
+		report := <-reports
+		world.halo.alive += report.alive
+		if report.skipped {
+			skipped++
+		}
+		for i, cell := range report.flipped {
+			c.events <- CellFlipped{
+				CompletedTurns: turn,
+				Cell:           cell,
+				Value:          report.values[i],
+			}
+		}
+	}
+	world.halo.stale = true
+	c.events <- TilesSkipped{
+		CompletedTurns: turn,
+		Tiles:          len(world.halo.reports),
+		Skipped:        skipped,
+	}
+}
+
+// load copies the tile's cells out of the field.
+func (t *tile) load(field [][]uint8) {
+	if t.backend == BitBackend {
+		t.packed = make([]packedRow, t.height+2)
+		t.nextPacked = make([]packedRow, t.height+2)
+		for y := range t.packed {
+			t.packed[y].words = make([]uint64, wordsPerRow(t.width))
+			t.nextPacked[y].words = make([]uint64, wordsPerRow(t.width))
+			if y > 0 && y <= t.height {
+				packRow(field[t.top+y-1][t.left:t.left+t.width], t.packed[y].words)
+			}
+		}
+		return
+	}
+	t.rows = newField(t.height+2, t.width+2)
+	t.next = newField(t.height+2, t.width+2)
+	for y := 1; y <= t.height; y++ {
+		copy(t.rows[y][1:], field[t.top+y-1][t.left:t.left+t.width])
+	}
+}
+
+func (t *tile) run(commands <-chan tileCommand, reports chan<- tileReport, states chan<- tileState) {
+	for command := range commands {
+		switch command {
+		case tileTurn:
+			t.exchange()
+			t.step()
+			reports <- t.report
+		case tileCollect:
+			states <- tileState{top: t.top, left: t.left, cells: t.cells()}
+		}
+	}
+}
+
+// exchange sends the tile's outer cells to its neighbours and receives their outer cells into its halo,
+// noting whether any of the halo cells changed.
+// The columns are exchanged first, so that the rows exchanged afterwards carry the corner cells
+// that the diagonal neighbours need.
+// Nothing sent is copied: the workers move in lockstep and never write the cells of the current turn.
+func (t *tile) exchange() {
+	t.haloChanged = false
+	for y := 0; y < t.height; y++ {
+		if t.backend == BitBackend {
+			words := t.packed[y+1].words
+			t.leftColumn[y] = uint8(words[0] & 1)
+			t.rightColumn[y] = uint8(words[(t.width-1)/64] >> uint((t.width-1)%64) & 1)
+		} else {
+			t.leftColumn[y], t.rightColumn[y] = t.rows[y+1][1], t.rows[y+1][t.width]
+		}
+	}
+	if t.toLeft != nil {
+		t.toLeft <- t.leftColumn
+	}
+	if t.toRight != nil {
+		t.toRight <- t.rightColumn
+	}
+	t.receiveColumn(t.fromLeft, 0)
+	t.receiveColumn(t.fromRight, t.width+1)
+
+	if t.backend == BitBackend {
+		if t.toUp != nil {
+			t.toUp <- haloRow{packed: t.packed[1]}
+		}
+		if t.toDown != nil {
+			t.toDown <- haloRow{packed: t.packed[t.height]}
+		}
+		t.receivePacked(t.fromUp, t.mirrorUp, &t.packed[0])
+		t.receivePacked(t.fromDown, t.mirrorDown, &t.packed[t.height+1])
+		return
+	}
+	if t.toUp != nil {
+		t.toUp <- haloRow{cells: t.rows[1]}
+	}
+	if t.toDown != nil {
+		t.toDown <- haloRow{cells: t.rows[t.height]}
+	}
+	t.receive(t.fromUp, t.mirrorUp, t.rows[0])
+	t.receive(t.fromDown, t.mirrorDown, t.rows[t.height+1])
+}
+
+// receiveColumn copies a column from a neighbour into the halo column at x, 0 on the left or width+1 on the right,
+// or clears it beyond a dead edge.
+func (t *tile) receiveColumn(from <-chan []uint8, x int) {
+	var column []uint8
+	if from != nil {
+		column = <-from
+	}
+	for y := 0; y < t.height; y++ {
+		cell := uint8(0)
+		if column != nil {
+			cell = column[y]
+		}
+		if t.backend != BitBackend {
+			t.haloChanged = t.haloChanged || t.rows[y+1][x] != cell
+			t.rows[y+1][x] = cell
+			continue
+		}
+		halo := &t.packed[y+1].right
+		if x == 0 {
+			halo = &t.packed[y+1].left
+		}
+		t.haloChanged = t.haloChanged || *halo != uint64(cell)
+		*halo = uint64(cell)
+	}
+}
+
+// receive copies a row from a neighbour into a halo row, or clears it beyond a dead edge.
+func (t *tile) receive(from <-chan haloRow, mirror bool, row []uint8) {
+	var cells []uint8
+	if from != nil {
+		cells = (<-from).cells
+	}
+	for x := range row {
+		cell := uint8(0)
+		switch {
+		case cells == nil:
+		case mirror:
+			cell = cells[len(cells)-1-x]
+		default:
+			cell = cells[x]
+		}
+		t.haloChanged = t.haloChanged || row[x] != cell
+		row[x] = cell
+	}
+}
+
+// receivePacked is the BitBackend equivalent of receive.
+func (t *tile) receivePacked(from <-chan haloRow, mirror bool, row *packedRow) {
+	var packed packedRow
+	if from != nil {
+		packed = (<-from).packed
+	}
+	left, right := packed.left, packed.right
+	if mirror {
+		left, right = right, left
+	}
+	t.haloChanged = t.haloChanged || row.left != left || row.right != right
+	row.left, row.right = left, right
+	for k := range row.words {
+		var word uint64
+		switch {
+		case packed.words == nil:
+		case mirror:
+			for x := k * 64; x < (k+1)*64 && x < t.width; x++ {
+				mirrored := t.width - 1 - x
+				word |= (packed.words[mirrored/64] >> uint(mirrored%64) & 1) << uint(x%64)
+			}
+		default:
+			word = packed.words[k]
+		}
+		t.haloChanged = t.haloChanged || row.words[k] != word
+		row.words[k] = word
+	}
+}
+
+// step computes the next turn of the tile and fills in the report.
+// A tile whose cells and halo are both unchanged is skipped, as it cannot change either.
+func (t *tile) step() {
+	t.report.flipped = t.report.flipped[:0]
+	t.report.values = t.report.values[:0]
+	t.report.skipped = !t.changed && !t.haloChanged
+	if t.report.skipped {
+		return
+	}
+	t.report.alive = 0
+	defer func() { t.changed = len(t.report.flipped) > 0 }()
+	if t.backend == BitBackend {
+		for y := 1; y <= t.height; y++ {
+			next := &t.nextPacked[y]
+			start := len(t.report.flipped)
+			t.report.flipped = nextPackedRow(t.rule, t.width, t.top+y-1,
+				t.packed[y-1], t.packed[y], t.packed[y+1], next.words, t.report.flipped)
+			for i := start; i < len(t.report.flipped); i++ {
+				cell := &t.report.flipped[i]
+				value := uint8(0)
+				if next.words[cell.X/64]>>uint(cell.X%64)&1 == 1 {
+					value = 255
+				}
+				t.report.values = append(t.report.values, value)
+				cell.X += t.left
+			}
+			for _, word := range next.words {
+				t.report.alive += bits.OnesCount64(word)
+			}
+		}
+		t.keepHalo()
+		t.packed, t.nextPacked = t.nextPacked, t.packed
+		return
+	}
+	for y := 1; y <= t.height; y++ {
+		up, row, down, next := t.rows[y-1], t.rows[y], t.rows[y+1], t.next[y]
+		for x := 1; x <= t.width; x++ {
+			aliveNeighbours := 0
+			for _, neighbour := range [8]uint8{
+				up[x-1], up[x], up[x+1],
+				row[x-1], row[x+1],
+				down[x-1], down[x], down[x+1],
+			} {
+				if neighbour == 255 {
+					aliveNeighbours++
+				}
+			}
+			nextCell := t.rule.next(row[x], aliveNeighbours)
+			if nextCell != row[x] {
+				t.report.flipped = append(t.report.flipped, util.Cell{X: t.left + x - 1, Y: t.top + y - 1})
+				t.report.values = append(t.report.values, nextCell)
+			}
+			if nextCell == 255 {
+				t.report.alive++
+			}
+			next[x] = nextCell
+		}
+	}
+	t.keepHalo()
+	t.rows, t.next = t.next, t.rows
+}
+
+// keepHalo copies the halo of the current turn into the next one, so that the next exchange
+// compares the halo it receives with the one the next turn was computed from.
+func (t *tile) keepHalo() {
+	if t.backend == BitBackend {
+		copy(t.nextPacked[0].words, t.packed[0].words)
+		copy(t.nextPacked[t.height+1].words, t.packed[t.height+1].words)
+		for y := range t.packed {
+			t.nextPacked[y].left, t.nextPacked[y].right = t.packed[y].left, t.packed[y].right
+		}
+		return
+	}
+	copy(t.next[0], t.rows[0])
+	copy(t.next[t.height+1], t.rows[t.height+1])
+	for y := range t.rows {
+		t.next[y][0], t.next[y][t.width+1] = t.rows[y][0], t.rows[y][t.width+1]
+	}
+}
+
+// cells returns a copy of the tile's cells, one byte per cell.
+func (t *tile) cells() [][]uint8 {
+	field := newField(t.height, t.width)
+	for y := range field {
+		if t.backend == BitBackend {
+			for x := range field[y] {
+				if t.packed[y+1].words[x/64]>>uint(x%64)&1 == 1 {
+					field[y][x] = 255
+				}
+			}
+		} else {
+			copy(field[y], t.rows[y+1][1:t.width+1])
+		}
+	}
+	return field
+}
@@ -0,0 +1,296 @@
+package gol
+
+import (
+	"errors"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Engine selects how the world is advanced from one turn to the next.
+type Engine int
+
+const (
+	// StepEngine advances the world one turn at a time using the worker threads.
+	StepEngine Engine = iota
+	// HashLifeEngine advances the world by 2^k turns at a time using a memoised quadtree.
+	// It only supports two-state rules on a torus whose width and height are powers of two;
+	// any other world always uses StepEngine.
+	HashLifeEngine
+)
+
+var engineNames = []string{"step", "hashlife"}
+
+// String returns the name of the engine, as accepted by Set.
+func (engine Engine) String() string {
+	if engine < 0 || int(engine) >= len(engineNames) {
+		return "Incorrect Engine"
+	}
+	return engineNames[engine]
+}
+
+// Set parses the name of an engine, so that an Engine can be used as a flag.Value.
+func (engine *Engine) Set(s string) error {
+	for i, name := range engineNames {
+		if s == name {
+			*engine = Engine(i)
+			return nil
+		}
+	}
+	return errors.New("unknown engine " + s)
+}
+
+// maxNodes is the number of nodes after which the HashLife caches are thrown away to bound memory use.
+const maxNodes = 1 << 22
+
+// node is a square of 2^level x 2^level cells. Nodes are canonical, so equal squares share a node.
+// The population of the very large nodes made by tiling the world may overflow,
+// but it is only ever inspected for nodes no larger than the world.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint
+	population     int
+}
+
+type nodeKey struct {
+	nw, ne, sw, se *node
+}
+
+type resultKey struct {
+	n    *node
+	step uint
+}
+
+// hashLife holds the canonical nodes and memoised results of a HashLife universe.
+type hashLife struct {
+	rule       Rule
+	dead, live *node
+	nodes      map[nodeKey]*node
+	results    map[resultKey]*node
+	tiles      map[*node]*node
+}
+
+func newHashLife(rule Rule) *hashLife {
+	return &hashLife{
+		rule:    rule,
+		dead:    &node{},
+		live:    &node{population: 1},
+		nodes:   make(map[nodeKey]*node),
+		results: make(map[resultKey]*node),
+		tiles:   make(map[*node]*node),
+	}
+}
+
+// hashLifeSupported reports whether the world can be advanced with HashLifeEngine.
+func (world *World) hashLifeSupported() bool {
+	isPowerOfTwo := func(n int) bool { return n > 0 && n&(n-1) == 0 }
+	return world.topology == Torus && world.rule.States <= 2 &&
+		isPowerOfTwo(world.width) && isPowerOfTwo(world.height)
+}
+
+// join returns the canonical node made of the four given quadrants.
+func (life *hashLife) join(nw, ne, sw, se *node) *node {
+	key := nodeKey{nw, ne, sw, se}
+	if n, ok := life.nodes[key]; ok {
+		return n
+	}
+	n := &node{
+		nw:         nw,
+		ne:         ne,
+		sw:         sw,
+		se:         se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	life.nodes[key] = n
+	return n
+}
+
+// tile returns a node twice the size of n, made of four copies of n.
+func (life *hashLife) tile(n *node) *node {
+	if t, ok := life.tiles[n]; ok {
+		return t
+	}
+	t := life.join(n, n, n, n)
+	life.tiles[n] = t
+	return t
+}
+
+// centre returns the node of half the size in the middle of n.
+func (life *hashLife) centre(n *node) *node {
+	return life.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// leafAt returns the cell at (x, y) of a level 2 node.
+func leafAt(n *node, x, y int) int {
+	q := n.nw
+	switch {
+	case x >= 2 && y >= 2:
+		q = n.se
+	case y >= 2:
+		q = n.sw
+	case x >= 2:
+		q = n.ne
+	}
+	x, y = x%2, y%2
+	switch {
+	case x == 1 && y == 1:
+		return q.se.population
+	case y == 1:
+		return q.sw.population
+	case x == 1:
+		return q.ne.population
+	}
+	return q.nw.population
+}
+
+// baseResult advances the centre of a level 2 node by one turn.
+func (life *hashLife) baseResult(n *node) *node {
+	var next [4]*node
+	for i, c := range [4][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}} {
+		aliveNeighbours := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					aliveNeighbours += leafAt(n, c[0]+dx, c[1]+dy)
+				}
+			}
+		}
+		alive := leafAt(n, c[0], c[1]) == 1
+		next[i] = life.dead
+		if alive && life.rule.Survival[aliveNeighbours] || !alive && life.rule.Birth[aliveNeighbours] {
+			next[i] = life.live
+		}
+	}
+	return life.join(next[0], next[1], next[2], next[3])
+}
+
+// result returns the centre of n, a node of level at least 2, advanced by 2^step turns.
+// step must be at most n.level-2.
+func (life *hashLife) result(n *node, step uint) *node {
+	key := resultKey{n, step}
+	if r, ok := life.results[key]; ok {
+		return r
+	}
+	var r *node
+	if n.level == 2 {
+		r = life.baseResult(n)
+	} else {
+		// The nine overlapping sub-squares of half the size.
+		sub := [9]*node{
+			n.nw, life.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), n.ne,
+			life.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), life.centre(n), life.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne),
+			n.sw, life.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), n.se,
+		}
+		fullSpeed := step == n.level-2
+		for i, s := range sub {
+			if fullSpeed {
+				sub[i] = life.result(s, step-1)
+			} else {
+				sub[i] = life.centre(s)
+			}
+		}
+		innerStep := step
+		if fullSpeed {
+			innerStep = step - 1
+		}
+		r = life.join(
+			life.result(life.join(sub[0], sub[1], sub[3], sub[4]), innerStep),
+			life.result(life.join(sub[1], sub[2], sub[4], sub[5]), innerStep),
+			life.result(life.join(sub[3], sub[4], sub[6], sub[7]), innerStep),
+			life.result(life.join(sub[4], sub[5], sub[7], sub[8]), innerStep),
+		)
+	}
+	life.results[key] = r
+	return r
+}
+
+// build returns the node of the given level whose top-left corner is at (x, y) in the field,
+// repeating the field in both directions as on a torus.
+func (life *hashLife) build(field [][]uint8, x, y int, level uint) *node {
+	if level == 0 {
+		if field[y%len(field)][x%len(field[0])] == 255 {
+			return life.live
+		}
+		return life.dead
+	}
+	half := 1 << (level - 1)
+	return life.join(
+		life.build(field, x, y, level-1),
+		life.build(field, x+half, y, level-1),
+		life.build(field, x, y+half, level-1),
+		life.build(field, x+half, y+half, level-1),
+	)
+}
+
+// write copies the part of n whose top-left corner is at (x, y) into the field, which must be all dead.
+func write(n *node, field [][]uint8, x, y int) {
+	if n.population == 0 || y >= len(field) || x >= len(field[0]) {
+		return
+	}
+	if n.level == 0 {
+		field[y][x] = 255
+		return
+	}
+	half := 1 << (n.level - 1)
+	write(n.nw, field, x, y)
+	write(n.ne, field, x+half, y)
+	write(n.sw, field, x, y+half)
+	write(n.se, field, x+half, y+half)
+}
+
+// advance returns the torus represented by the square node n, advanced by 2^step turns.
+// The torus is repeated in a node large enough for its centre to be advanced by 2^step turns
+// and for the offset of that centre to be a multiple of the size of n.
+func (life *hashLife) advance(n *node, step uint) *node {
+	t := life.tile(life.tile(n))
+	for t.level < step+2 {
+		t = life.tile(t)
+	}
+	r := life.result(t, step)
+	for r.level > n.level {
+		r = r.nw
+	}
+	return r
+}
+
+// jumpWorld advances the world by the largest power of two turns that does not exceed the given number of turns,
+// and returns the number of turns completed.
+// CellFlipped events are sent for every cell that differs between the old and the new world,
+// so cells that change and change back during the jump are not reported.
+func (world *World) jumpWorld(turns int, turn int, c distributorChannels) int {
+	if world.life == nil || len(world.life.nodes) > maxNodes {
+		world.life = newHashLife(world.rule)
+		size := world.width
+		if world.height > size {
+			size = world.height
+		}
+		level := uint(0)
+		for 1<<level < size {
+			level++
+		}
+		world.root = world.life.build(world.field, 0, 0, level)
+	}
+
+	step := uint(0)
+	for step < 62 && 1<<(step+1) <= turns {
+		step++
+	}
+	world.root = world.life.advance(world.root, step)
+	jumped := 1 << step
+
+	field := newField(world.height, world.width)
+	write(world.root, field, 0, 0)
+	for y := range field {
+		for x := range field[y] {
+			if field[y][x] != world.field[y][x] {
+				c.events <- CellFlipped{
+					CompletedTurns: turn + jumped - 1,
+					Cell:           util.Cell{X: x, Y: y},
+					Value:          field[y][x],
+				}
+			}
+		}
+	}
+	world.field = field
+	return jumped
+}
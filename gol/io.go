@@ -1,21 +1,23 @@
 package gol
 
 import (
+	"bufio"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strconv"
-	"strings"
-	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type ioChannels struct {
 	command <-chan ioCommand
 	idle    chan<- bool
+	// errors is sent the first error of the io goroutine, which carries on handling commands afterwards.
+	errors chan<- error
 
 	filename <-chan string
-	output   <-chan uint8
-	input    chan<- uint8
+	output   <-chan []uint8
+	input    chan<- []uint8
+
+	checkpointOutput <-chan checkpoint
+	checkpointInput  chan<- checkpoint
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -33,97 +35,150 @@ type ioCommand uint8
 //	ioOutput 	= 0
 //	ioInput 	= 1
 //	ioCheckIdle = 2
+//	ioCheckpoint = 3
+//	ioResume = 4
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioCheckpoint
+	ioResume
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
+// writeImage receives the rows of the world and writes them in the format chosen by the extension of the filename,
+// which is a pgm file unless it is one of the pattern formats. All the rows are received before the file is created,
+// so that the distributor is never left waiting to send them.
+func (io *ioState) writeImage() error {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
 	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+	for y := range world {
+		world[y] = <-io.channels.output
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
+	file, ioError := io.createOutput(filename)
+	if ioError != nil {
+		return ioError
 	}
+	defer file.Close()
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+	out := bufio.NewWriter(file)
+	if ioError = io.params.fileFormat(filename).write(out, world, io.params.Rule); ioError == nil {
+		ioError = out.Flush()
+	}
+	if ioError != nil {
+		return fmt.Errorf("writing %v: %v", filename, ioError)
+	}
+	if ioError = file.commit(); ioError != nil {
+		return ioError
 	}
-
-	ioError = file.Sync()
-	util.Check(ioError)
 
 	fmt.Println("File", filename, "output done!")
+	return nil
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readImage opens a pbm, pgm or png file or a pattern, chosen by the extension of the path, and sends its data
+// a row at a time. Patterns are centred in the world, while images and bounded patterns must be the size of the world.
+// No rows are sent if the file cannot be read.
+func (io *ioState) readImage() error {
 
-	// Request a filename from the distributor.
+	// Request a path from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
-
-	fields := strings.Fields(string(data))
-
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	file, ioError := os.Open(filename)
+	if ioError != nil {
+		return ioError
 	}
+	defer file.Close()
 
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	pattern, ioError := io.params.fileFormat(filename).read(file)
+	if ioError != nil {
+		return fmt.Errorf("reading %v: %v", filename, ioError)
 	}
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	if (!isPattern(filename) || pattern.Bounded) && (pattern.Width != io.params.ImageWidth || pattern.Height != io.params.ImageHeight) {
+		return fmt.Errorf("%v is %dx%d, not %dx%d",
+			filename, pattern.Width, pattern.Height, io.params.ImageWidth, io.params.ImageHeight)
+	}
+	if io.params.Threshold > 0 {
+		pattern.threshold(io.params.Threshold)
+	} else if isPng(filename) {
+		pattern.threshold(pngThreshold)
+	}
+	world, ioError := pattern.centre(io.params.ImageWidth, io.params.ImageHeight)
+	if ioError != nil {
+		return fmt.Errorf("%v: %v", filename, ioError)
 	}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	for _, row := range world {
+		io.channels.input <- row
 	}
 
-	image := []byte(fields[4])
+	fmt.Println("File", filename, "input done!")
+	return nil
+}
 
-	for _, b := range image {
-		io.channels.input <- b
+// readHeader returns the size of the image or pattern at path, and the rule given by an RLE pattern.
+// Only the header of an image is read, but patterns are read in full. PNG images are sized in cells of scale x scale pixels.
+func readHeader(path string, scale int) (pattern, error) {
+	var header pattern
+	file, err := os.Open(path)
+	if err != nil {
+		return header, err
 	}
+	defer file.Close()
+	if isPattern(path) {
+		header, err = formatOf(path).read(file)
+		if err != nil {
+			err = fmt.Errorf("reading %v: %v", path, err)
+		}
+		header.Cells = nil
+		return header, err
+	}
+	if isPng(path) {
+		header, err = readPngHeader(file, scale)
+		if err != nil {
+			err = fmt.Errorf("reading header of %v: %v", path, err)
+		}
+		return header, err
+	}
+	pnm, err := readPnmHeader(bufio.NewReader(file))
+	if err != nil {
+		return header, fmt.Errorf("reading header of %v: %v", path, err)
+	}
+	header.Width, header.Height = pnm.width, pnm.height
+	return header, nil
+}
 
-	fmt.Println("File", filename, "input done!")
+// BoardSize returns the size of the world for p. A pgm image at p.InputPath gives the size of the world,
+// while a pattern is centred in a world of p.ImageWidth x p.ImageHeight, or of the size of the pattern
+// if those are 0.
+func BoardSize(p Params) (width, height int, err error) {
+	p, err = p.withInput()
+	return p.ImageWidth, p.ImageHeight, err
+}
+
+// withInput returns p with the size of the world, and the rule of an RLE pattern if p does not give one,
+// taken from the input at p.InputPath.
+func (p Params) withInput() (Params, error) {
+	if p.InputPath == "" || p.Resume != "" {
+		return p, nil
+	}
+	header, err := readHeader(p.InputPath, p.PngScale)
+	if err != nil {
+		return p, err
+	}
+	if !isPattern(p.InputPath) || header.Bounded || p.ImageWidth == 0 || p.ImageHeight == 0 {
+		p.ImageWidth, p.ImageHeight = header.Width, header.Height
+	}
+	if p.Rule == (Rule{}) {
+		p.Rule = header.Rule
+	}
+	if p.ImageWidth <= 0 || p.ImageHeight <= 0 {
+		return p, fmt.Errorf("%v is empty, so the size of the world must be given", p.InputPath)
+	}
+	return p, nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -135,15 +190,30 @@ func startIo(p Params, c ioChannels) {
 
 	for {
 		select {
-		// Block and wait for requests from the distributor
-		case command := <-io.channels.command:
+		// Block and wait for requests from the distributor, until it closes the channel.
+		case command, ok := <-io.channels.command:
+			if !ok {
+				return
+			}
+			var err error
 			switch command {
 			case ioInput:
-				io.readPgmImage()
+				err = io.readImage()
 			case ioOutput:
-				io.writePgmImage()
+				err = io.writeImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
+			case ioCheckpoint:
+				err = io.writeCheckpointFile()
+			case ioResume:
+				err = io.readCheckpointFile()
+			}
+			// Only the first error is kept until the distributor receives it.
+			if err != nil {
+				select {
+				case io.channels.errors <- err:
+				default:
+				}
 			}
 		}
 	}
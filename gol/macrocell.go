@@ -0,0 +1,336 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Patterns in Golly's macrocell format are quadtrees in which identical squares are written only once,
+// so that large sparse worlds stay small:
+//
+//	[M2] (golly 4.0)
+//	#R B3/S23:T512,512
+//	$$..*$...*$.***$
+//	4 0 0 0 1
+//	...
+//
+// Each line after the header is a node, numbered from 1. A leaf is an 8x8 square of '.' for dead and '*' for
+// alive cells, with '$' ending each row. Any other node is a square of 2^k cells written as k followed by its
+// north west, north east, south west and south east quarters, with 0 for an empty quarter. The last node is the
+// whole pattern, centred on the origin. Patterns of Generations rules have no leaves, and instead their nodes
+// of 2x2 cells are written as 1 followed by the states of the four cells.
+//
+// A suffix of the rule such as :T512,512 is Golly's notation for a bounded grid, which is written with the size
+// of the world so that it is read back at the same size. Patterns without it are as large as their alive cells.
+
+const (
+	// macrocellHeader starts the first line of a macrocell pattern.
+	macrocellHeader = "[M2]"
+	// macrocellLeafLevel is the level of the 8x8 leaves of patterns of rules with two states.
+	macrocellLeafLevel = 3
+	// maxMacrocellLevel is the level of the largest macrocell pattern read, so that coordinates fit in an int.
+	maxMacrocellLevel = 62
+)
+
+// macrocellNode is a node of a macrocell pattern: a leaf of 8x8 cells, one bit per cell,
+// or the indices of its quarters, which are the states of its cells at level 1.
+// Bounds is the smallest rectangle holding the cells of the node that are not dead, which is empty if they all are.
+type macrocellNode struct {
+	level    int
+	leaf     uint64
+	children [4]int
+	bounds   image.Rectangle
+}
+
+// readMacrocell reads a pattern in the macrocell format.
+func readMacrocell(r io.Reader) (pattern, error) {
+	var p pattern
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), macrocellHeader) {
+		return p, errors.New("not a macrocell pattern: it does not start with " + macrocellHeader)
+	}
+	gridWidth, gridHeight := 0, 0
+	nodes := []macrocellNode{{}}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#R"):
+			rule := strings.TrimSpace(line[2:])
+			if colon := strings.IndexByte(rule, ':'); colon != -1 {
+				grid := rule[colon+1:]
+				var rest string
+				n, _ := fmt.Sscanf(grid+" ", "%1s%d,%d%s", &rest, &gridWidth, &gridHeight, &rest)
+				if n != 3 || gridWidth <= 0 || gridHeight <= 0 {
+					return p, fmt.Errorf("invalid bounded grid in macrocell rule %q", rule)
+				}
+				if err := checkPatternSize(gridWidth, gridHeight); err != nil {
+					return p, err
+				}
+				rule = rule[:colon]
+			}
+			var err error
+			if p.Rule, err = parseRLERule(rule); err != nil {
+				return p, err
+			}
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		}
+		node, err := parseMacrocellNode(line, nodes)
+		if err != nil {
+			return p, fmt.Errorf("macrocell node %v: %v", len(nodes), err)
+		}
+		nodes = append(nodes, node)
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	if len(nodes) == 1 {
+		return p, errors.New("macrocell pattern has no nodes")
+	}
+	root := len(nodes) - 1
+	half := 1 << uint(nodes[root].level-1)
+	states := p.Rule.orDefault().States
+
+	// Cells are placed with the top left of the root at 0,0, and then moved by left and top into the pattern.
+	// The bounds of the nodes give the size of the pattern before any cell is visited, so that every cell
+	// visited is alive and inside the pattern.
+	bounds := nodes[root].bounds
+	var left, top int
+	if gridWidth > 0 {
+		p.Width, p.Height, p.Bounded = gridWidth, gridHeight, true
+		left, top = half-gridWidth/2, half-gridHeight/2
+		if !bounds.In(image.Rect(left, top, left+gridWidth, top+gridHeight)) {
+			return p, fmt.Errorf("macrocell pattern has cells outside its %vx%v grid", p.Width, p.Height)
+		}
+	} else {
+		left, top = bounds.Min.X, bounds.Min.Y
+		p.Width, p.Height = bounds.Dx(), bounds.Dy()
+		if err := checkPatternSize(p.Width, p.Height); err != nil {
+			return p, err
+		}
+	}
+	p.Cells = newField(p.Height, p.Width)
+	visitMacrocell(nodes, root, 0, 0, func(x, y, state int) {
+		p.Cells[y-top][x-left] = stateLevel(state, states)
+	})
+	return p, nil
+}
+
+// parseMacrocellNode parses a line of a macrocell pattern, given the nodes before it.
+func parseMacrocellNode(line string, nodes []macrocellNode) (macrocellNode, error) {
+	node := macrocellNode{level: macrocellLeafLevel}
+	if strings.IndexAny(line[:1], ".*$") == 0 {
+		x, y := 0, 0
+		for _, c := range line {
+			switch {
+			case c == '$':
+				x, y = 0, y+1
+				continue
+			case x >= 8 || y >= 8:
+				return node, errors.New("leaf is larger than 8x8")
+			case c == '*':
+				node.leaf |= 1 << uint(8*y+x)
+			case c != '.':
+				return node, fmt.Errorf("invalid character %q in leaf", c)
+			}
+			x++
+		}
+		node.bounds = node.leafBounds()
+		return node, nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return node, fmt.Errorf("invalid node %q", line)
+	}
+	var err error
+	if node.level, err = strconv.Atoi(fields[0]); err != nil || node.level < 1 || node.level > maxMacrocellLevel {
+		return node, fmt.Errorf("invalid level in node %q", line)
+	}
+	for i := range node.children {
+		child, err := strconv.Atoi(fields[i+1])
+		switch {
+		case err != nil || child < 0:
+			return node, fmt.Errorf("invalid quarter in node %q", line)
+		case node.level == 1 && child > 255:
+			return node, fmt.Errorf("invalid state in node %q", line)
+		case node.level > 1 && child >= len(nodes):
+			return node, fmt.Errorf("node %q refers to a later node", line)
+		case node.level > 1 && child > 0 && nodes[child].level != node.level-1:
+			return node, fmt.Errorf("node %q has a quarter of the wrong size", line)
+		}
+		node.children[i] = child
+	}
+	for i, child := range node.children {
+		var bounds image.Rectangle
+		switch {
+		case node.level == 1 && child != 0:
+			bounds = image.Rect(0, 0, 1, 1)
+		case node.level > 1:
+			bounds = nodes[child].bounds
+		}
+		if !bounds.Empty() {
+			half := 1 << uint(node.level-1)
+			node.bounds = node.bounds.Union(bounds.Add(image.Pt(i%2*half, i/2*half)))
+		}
+	}
+	return node, nil
+}
+
+// leafBounds returns the bounds of the alive cells of a leaf.
+func (node macrocellNode) leafBounds() image.Rectangle {
+	var bounds image.Rectangle
+	for i := uint(0); i < 64; i++ {
+		if node.leaf&(1<<i) != 0 {
+			bounds = bounds.Union(image.Rect(int(i%8), int(i/8), int(i%8)+1, int(i/8)+1))
+		}
+	}
+	return bounds
+}
+
+// visitMacrocell calls visit with the position and state of every cell of a node that is not dead,
+// where the top left of the node is at x, y.
+func visitMacrocell(nodes []macrocellNode, index, x, y int, visit func(x, y, state int)) {
+	node := nodes[index]
+	switch {
+	case node.bounds.Empty():
+	case node.level == 1:
+		for i, state := range node.children {
+			if state != 0 {
+				visit(x+i%2, y+i/2, state)
+			}
+		}
+	case node.leaf != 0:
+		for i := uint(0); i < 64; i++ {
+			if node.leaf&(1<<i) != 0 {
+				visit(x+int(i%8), y+int(i/8), 1)
+			}
+		}
+	default:
+		half := 1 << uint(node.level-1)
+		for i, child := range node.children {
+			visitMacrocell(nodes, child, x+i%2*half, y+i/2*half, visit)
+		}
+	}
+}
+
+// macrocellWriter writes the nodes of a world to a macrocell pattern, writing each distinct node once.
+type macrocellWriter struct {
+	out           *bufio.Writer
+	cells         [][]uint8
+	states        int
+	width, height int
+	left, top     int
+	nodes         map[string]int
+}
+
+// writeMacrocell writes the world in the macrocell format, as a bounded grid of its size.
+func writeMacrocell(w io.Writer, cells [][]uint8, rule Rule) error {
+	rule = rule.orDefault()
+	m := macrocellWriter{
+		out:    bufio.NewWriter(w),
+		cells:  cells,
+		states: rule.States,
+		height: len(cells),
+		nodes:  make(map[string]int),
+	}
+	if m.height > 0 {
+		m.width = len(cells[0])
+	}
+	fmt.Fprintln(m.out, macrocellHeader, "(uk.ac.bris.cs/gameoflife)")
+	fmt.Fprintf(m.out, "#R %v:T%v,%v\n", rule, m.width, m.height)
+
+	// The root is the smallest square centred on the origin that holds the grid, which Golly centres
+	// with its top left cell at -width/2, -height/2.
+	level := macrocellLeafLevel
+	if m.states > 2 {
+		level = 1
+	}
+	fits := func(half, size int) bool {
+		return half >= size/2 && half > size-1-size/2
+	}
+	for !fits(1<<uint(level-1), m.width) || !fits(1<<uint(level-1), m.height) {
+		level++
+	}
+	half := 1 << uint(level-1)
+	m.left, m.top = half-m.width/2, half-m.height/2
+
+	if m.node(level, 0, 0) == 0 {
+		if level == macrocellLeafLevel && m.states <= 2 {
+			fmt.Fprintln(m.out, "$")
+		} else {
+			fmt.Fprintln(m.out, level, 0, 0, 0, 0)
+		}
+	}
+	return m.out.Flush()
+}
+
+// cell returns the cell at x, y from the top left of the root, which is dead outside the world.
+func (m *macrocellWriter) cell(x, y int) uint8 {
+	x, y = x-m.left, y-m.top
+	if x < 0 || y < 0 || x >= m.width || y >= m.height {
+		return 0
+	}
+	return m.cells[y][x]
+}
+
+// node writes the node of the given level with its top left at x, y, after the nodes it is made of,
+// and returns its index, or 0 if all its cells are dead.
+func (m *macrocellWriter) node(level, x, y int) int {
+	size := 1 << uint(level)
+	if x+size <= m.left || y+size <= m.top || x >= m.left+m.width || y >= m.top+m.height {
+		return 0
+	}
+	var line string
+	switch {
+	case level == macrocellLeafLevel && m.states <= 2:
+		var sb strings.Builder
+		for dy := 0; dy < 8; dy++ {
+			end := 0
+			for dx := 0; dx < 8; dx++ {
+				if m.cell(x+dx, y+dy) == 255 {
+					end = dx + 1
+				}
+			}
+			for dx := 0; dx < end; dx++ {
+				if m.cell(x+dx, y+dy) == 255 {
+					sb.WriteByte('*')
+				} else {
+					sb.WriteByte('.')
+				}
+			}
+			sb.WriteByte('$')
+		}
+		line = strings.TrimRight(sb.String(), "$") + "$"
+		if line == "$" {
+			return 0
+		}
+	default:
+		var quarters [4]int
+		for i := range quarters {
+			if level == 1 {
+				quarters[i] = levelState(m.cell(x+i%2, y+i/2), m.states)
+			} else {
+				half := size / 2
+				quarters[i] = m.node(level-1, x+i%2*half, y+i/2*half)
+			}
+		}
+		if quarters == [4]int{} {
+			return 0
+		}
+		line = fmt.Sprintf("%v %v %v %v %v", level, quarters[0], quarters[1], quarters[2], quarters[3])
+	}
+	if index, ok := m.nodes[line]; ok {
+		return index
+	}
+	m.out.WriteString(line + "\n")
+	m.nodes[line] = len(m.nodes) + 1
+	return len(m.nodes)
+}
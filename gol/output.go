@@ -0,0 +1,148 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultOutputDir is the directory images and checkpoints are written to when Params.OutputDir is empty.
+	defaultOutputDir = "out"
+	// defaultOutputTemplate is the template used when Params.OutputTemplate is empty, followed by the extension of the input.
+	defaultOutputTemplate = "{width}x{height}x{turn}"
+)
+
+// outputDir returns the directory that images and checkpoints are written to.
+func (p Params) outputDir() string {
+	if p.OutputDir == "" {
+		return defaultOutputDir
+	}
+	return p.OutputDir
+}
+
+// outputTemplate returns the template for the names of the images.
+func (p Params) outputTemplate() string {
+	if p.OutputTemplate == "" && (isPattern(p.InputPath) || isPng(p.InputPath)) && p.Resume == "" {
+		return defaultOutputTemplate + strings.ToLower(filepath.Ext(p.InputPath))
+	}
+	if p.OutputTemplate == "" {
+		return defaultOutputTemplate + ".pgm"
+	}
+	return p.OutputTemplate
+}
+
+// outputValues returns the values of the placeholders of an output template.
+func outputValues(name string, width, height, turn int) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"width":  width,
+		"height": height,
+		"turn":   turn,
+	}
+}
+
+// expandTemplate replaces the placeholders of an output template, such as "{name}-{turn:08d}.pgm", with their values.
+// A placeholder is a key in braces, optionally followed by a colon and a fmt verb without the %,
+// such as {turn:08d} for the turn padded with zeros to 8 digits.
+func expandTemplate(template string, values map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	original := template
+	for {
+		start := strings.IndexAny(template, "{}")
+		if start == -1 {
+			sb.WriteString(template)
+			break
+		}
+		end := strings.IndexByte(template[start:], '}') + start
+		if template[start] == '}' || end < start {
+			return "", errors.New("unbalanced braces in output template " + original)
+		}
+		sb.WriteString(template[:start])
+		key, verb := template[start+1:end], "v"
+		if colon := strings.IndexByte(key, ':'); colon != -1 {
+			key, verb = key[:colon], key[colon+1:]
+		}
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("unknown placeholder {%v} in output template", key)
+		}
+		formatted := fmt.Sprintf("%"+verb, value)
+		if strings.Contains(formatted, "%!") {
+			return "", fmt.Errorf("invalid format %v for {%v} in output template", verb, key)
+		}
+		sb.WriteString(formatted)
+		template = template[end+1:]
+	}
+	name := sb.String()
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", errors.New("output template " + original + " does not give a file name")
+	}
+	return name, nil
+}
+
+// inputName returns the name of the input a simulation was loaded from, such as "glider" for patterns/glider.pgm.
+func inputName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// withExtension replaces the extension of a file name.
+func withExtension(name, extension string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + extension
+}
+
+// outputFile is a file being written to the output directory. When writing atomically it is first written
+// to a temporary file in the same directory, which replaces the file on commit so that the file is never
+// seen half written.
+type outputFile struct {
+	*os.File
+	path string
+}
+
+// createOutput creates the output directory if needed and then a file in it.
+func (io *ioState) createOutput(name string) (*outputFile, error) {
+	dir := io.params.outputDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name)
+	if !io.params.AtomicOutput {
+		file, err := os.Create(path)
+		return &outputFile{File: file, path: path}, err
+	}
+	file, err := ioutil.TempFile(dir, "."+name+".*.tmp")
+	return &outputFile{File: file, path: path}, err
+}
+
+// commit closes the file. When writing atomically it first makes sure that everything written
+// has reached the disk, and then moves the file into place.
+func (file *outputFile) commit() error {
+	atomic := file.Name() != file.path
+	if atomic {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := file.File.Close(); err != nil {
+		return err
+	}
+	if atomic {
+		if err := os.Rename(file.Name(), file.path); err != nil {
+			_ = os.Remove(file.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the file if it has not been committed, removing it when writing atomically.
+func (file *outputFile) Close() error {
+	err := file.File.Close()
+	if file.Name() != file.path && err == nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package gol
+
+import (
+	"errors"
+	"math/bits"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Backend selects how the world is stored and how the workers update it.
+type Backend int
+
+const (
+	// ByteBackend stores one byte per cell and counts the neighbours of every cell separately.
+	ByteBackend Backend = iota
+	// BitBackend packs 64 cells into every uint64 and counts the neighbours of a whole word at once
+	// using bit-sliced adders. It only supports two-state rules, so Generations rules always use ByteBackend.
+	BitBackend
+)
+
+var backendNames = []string{"byte", "bit"}
+
+// String returns the name of the backend, as accepted by Set.
+func (backend Backend) String() string {
+	if backend < 0 || int(backend) >= len(backendNames) {
+		return "Incorrect Backend"
+	}
+	return backendNames[backend]
+}
+
+// Set parses the name of a backend, so that a Backend can be used as a flag.Value.
+func (backend *Backend) Set(s string) error {
+	for i, name := range backendNames {
+		if s == name {
+			*backend = Backend(i)
+			return nil
+		}
+	}
+	return errors.New("unknown backend " + s)
+}
+
+// PackedRegion is the bit-packed equivalent of a Region.
+type PackedRegion struct {
+	// rows holds the region with a halo row above and below.
+	rows                 []packedRow
+	start, height, width int
+	rule                 Rule
+}
+
+// packedRow is a row of cells packed into words, where cell x is stored in bit x%64 of word x/64,
+// together with the cells just beyond its left and right edges in bit 0 of left and right.
+type packedRow struct {
+	words       []uint64
+	left, right uint64
+}
+
+// wordsPerRow returns the number of words needed to store a row of cells.
+func wordsPerRow(width int) int {
+	return (width + 63) / 64
+}
+
+// packRow packs a row of cells into words, treating only 255 as alive.
+func packRow(row []uint8, words []uint64) {
+	for x, cell := range row {
+		if cell == 255 {
+			words[x/64] |= 1 << uint(x%64)
+		}
+	}
+}
+
+// packField packs every row of the world's field.
+func (world *World) packField() {
+	world.bits = make([][]uint64, world.height)
+	for y := range world.bits {
+		world.bits[y] = make([]uint64, wordsPerRow(world.width))
+		packRow(world.field[y], world.bits[y])
+	}
+}
+
+// packedHaloRow returns row y of the world, which may lie just outside the world, with its halo cells.
+// Rows inside the world share their words with world.bits.
+func (world *World) packedHaloRow(y int) packedRow {
+	var row packedRow
+	if y >= 0 && y < world.height {
+		row.words = world.bits[y]
+	} else {
+		row.words = make([]uint64, wordsPerRow(world.width))
+		for x := 0; x < world.width; x++ {
+			if world.cell(x, y) == 255 {
+				row.words[x/64] |= 1 << uint(x%64)
+			}
+		}
+	}
+	if world.cell(-1, y) == 255 {
+		row.left = 1
+	}
+	if world.cell(world.width, y) == 255 {
+		row.right = 1
+	}
+	return row
+}
+
+func (world *World) makePackedHalo(w int) PackedRegion {
+	start, end := world.regionBounds(w)
+	region := PackedRegion{
+		start:  start,
+		height: end - start,
+		width:  world.width,
+		rule:   world.rule,
+	}
+	for y := start - 1; y <= end; y++ {
+		region.rows = append(region.rows, world.packedHaloRow(y))
+	}
+	return region
+}
+
+// halfAdd adds two bit-sliced one-bit numbers.
+func halfAdd(a, b uint64) (sum, carry uint64) {
+	return a ^ b, a & b
+}
+
+// fullAdd adds three bit-sliced one-bit numbers.
+func fullAdd(a, b, c uint64) (sum, carry uint64) {
+	t := a ^ b
+	return t ^ c, a&b | t&c
+}
+
+// countNeighbours adds up eight neighbour words bit by bit, returning the four bits of each count.
+func countNeighbours(n [8]uint64) (ones, twos, fours, eights uint64) {
+	s0, c0 := fullAdd(n[0], n[1], n[2])
+	s1, c1 := fullAdd(n[3], n[4], n[5])
+	s2, c2 := halfAdd(n[6], n[7])
+	ones, c3 := fullAdd(s0, s1, s2)
+	t0, d0 := fullAdd(c0, c1, c2)
+	twos, d1 := halfAdd(t0, c3)
+	fours, eights = halfAdd(d0, d1)
+	return
+}
+
+// west returns the word of cells to the left of each cell in word k of the row.
+func (row packedRow) west(k int) uint64 {
+	if k == 0 {
+		return row.words[0]<<1 | row.left
+	}
+	return row.words[k]<<1 | row.words[k-1]>>63
+}
+
+// east returns the word of cells to the right of each cell in word k of a row of the given width.
+func (row packedRow) east(k, width int) uint64 {
+	if k+1 == len(row.words) {
+		return row.words[k]>>1 | row.right<<uint((width-1)%64)
+	}
+	return row.words[k]>>1 | row.words[k+1]<<63
+}
+
+// nextPackedRow computes row y of the next turn into next from the rows above, at and below it,
+// and appends the cells that flipped.
+func nextPackedRow(rule Rule, width, y int, up, row, down packedRow, next []uint64, flipped []util.Cell) []util.Cell {
+	words := len(next)
+	lastMask := ^uint64(0)
+	if width%64 != 0 {
+		lastMask = 1<<uint(width%64) - 1
+	}
+	for k := 0; k < words; k++ {
+		ones, twos, fours, eights := countNeighbours([8]uint64{
+			up.west(k), up.words[k], up.east(k, width),
+			row.west(k), row.east(k, width),
+			down.west(k), down.words[k], down.east(k, width),
+		})
+		alive := row.words[k]
+		var cells uint64
+		for n := 0; n <= 8; n++ {
+			if !rule.Birth[n] && !rule.Survival[n] {
+				continue
+			}
+			equal := ^uint64(0)
+			for bit, plane := range [4]uint64{ones, twos, fours, eights} {
+				if n&(1<<uint(bit)) != 0 {
+					equal &= plane
+				} else {
+					equal &= ^plane
+				}
+			}
+			if rule.Birth[n] {
+				cells |= equal &^ alive
+			}
+			if rule.Survival[n] {
+				cells |= equal & alive
+			}
+		}
+		if k == words-1 {
+			cells &= lastMask
+		}
+		next[k] = cells
+		for diff := cells ^ alive; diff != 0; diff &= diff - 1 {
+			flipped = append(flipped, util.Cell{
+				X: k*64 + bits.TrailingZeros64(diff),
+				Y: y,
+			})
+		}
+	}
+	return flipped
+}
+
+func (region *PackedRegion) updateRegion(regionCh chan<- [][]uint64, flippedCh chan<- []util.Cell) {
+	haloOffset := 1
+	rows := make([][]uint64, region.height)
+	flipped := []util.Cell{}
+	for y := haloOffset; y < region.height+haloOffset; y++ {
+		rows[y-haloOffset] = make([]uint64, wordsPerRow(region.width))
+		flipped = nextPackedRow(
+			region.rule, region.width, y-haloOffset+region.start,
+			region.rows[y-1], region.rows[y], region.rows[y+1],
+			rows[y-haloOffset], flipped,
+		)
+	}
+	regionCh <- rows
+	flippedCh <- flipped
+}
+
+// updatePackedWorld is the BitBackend equivalent of updateWorld.
+// The field is kept in step with the packed rows by updating only the cells that flipped.
+func (world *World) updatePackedWorld(turn int, c distributorChannels) {
+	regionCh := make([]chan [][]uint64, world.threads)
+	for i := range regionCh {
+		regionCh[i] = make(chan [][]uint64)
+	}
+
+	flippedCh := make(chan []util.Cell)
+
+	for w := 0; w < world.threads; w++ {
+		region := world.makePackedHalo(w)
+		go region.updateRegion(regionCh[w], flippedCh)
+	}
+
+	var newBits [][]uint64
+	for i := 0; i < world.threads; i++ {
+		newBits = append(newBits, <-regionCh[i]...)
+		flipped := <-flippedCh
+		for _, cell := range flipped {
+			world.field[cell.Y][cell.X] = 0
+			if newBits[cell.Y][cell.X/64]&(1<<uint(cell.X%64)) != 0 {
+				world.field[cell.Y][cell.X] = 255
+			}
+			c.events <- CellFlipped{
+				CompletedTurns: turn,
+				Cell:           cell,
+				Value:          world.field[cell.Y][cell.X],
+			}
+		}
+	}
+
+	world.bits = newBits
+}
@@ -0,0 +1,195 @@
+package gol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Worlds are written to PNG images with each cell drawn as a square of Scale x Scale pixels, coloured from
+// the dead colour of a Palette to its alive colour by the grey level of the cell, so that the dying states of
+// Generations rules are shown between the two. PNG images are read back by sampling the middle pixel of each
+// square, and cells whose colour is nearer to the alive colour than to the dead colour are alive.
+
+// pngThreshold is the grey level at or above which the cells of a PNG image are alive, unless Params.Threshold is set.
+const pngThreshold = 128
+
+// Palette gives the colours of dead and alive cells in PNG images.
+// The zero Palette is grey: black for dead cells and white for alive ones, as in pgm images.
+type Palette struct {
+	Dead, Alive color.RGBA
+}
+
+var (
+	greyPalette     = Palette{Dead: color.RGBA{0, 0, 0, 255}, Alive: color.RGBA{255, 255, 255, 255}}
+	invertedPalette = Palette{Dead: color.RGBA{255, 255, 255, 255}, Alive: color.RGBA{0, 0, 0, 255}}
+)
+
+// String returns the name of the palette, or its dead and alive colours as #rrggbb:#rrggbb, as accepted by Set.
+func (palette Palette) String() string {
+	switch palette.orDefault() {
+	case greyPalette:
+		return "grey"
+	case invertedPalette:
+		return "inverted"
+	}
+	return fmt.Sprintf("#%02x%02x%02x:#%02x%02x%02x",
+		palette.Dead.R, palette.Dead.G, palette.Dead.B, palette.Alive.R, palette.Alive.G, palette.Alive.B)
+}
+
+// Set parses grey, inverted, or the dead and alive colours written as #rrggbb:#rrggbb,
+// so that a Palette can be used as a flag.Value.
+func (palette *Palette) Set(s string) error {
+	switch s {
+	case "grey":
+		*palette = Palette{}
+		return nil
+	case "inverted":
+		*palette = invertedPalette
+		return nil
+	}
+	var parsed Palette
+	colours := strings.Split(s, ":")
+	if len(colours) != 2 {
+		return errors.New("invalid palette " + s + ", expected grey, inverted or #rrggbb:#rrggbb")
+	}
+	for i, c := range []*color.RGBA{&parsed.Dead, &parsed.Alive} {
+		var rest string
+		n, _ := fmt.Sscanf(colours[i]+" ", "#%02x%02x%02x%s", &c.R, &c.G, &c.B, &rest)
+		if n != 3 || len(colours[i]) != 7 {
+			return errors.New("invalid colour " + colours[i] + " in palette, expected #rrggbb")
+		}
+		c.A = 255
+	}
+	if parsed.Dead == parsed.Alive {
+		return errors.New("the dead and alive colours of palette " + s + " are the same")
+	}
+	*palette = parsed
+	return nil
+}
+
+func (palette Palette) orDefault() Palette {
+	if palette == (Palette{}) {
+		return greyPalette
+	}
+	return palette
+}
+
+// colours returns the colours of the 256 grey levels, from the dead colour at 0 to the alive colour at 255.
+func (palette Palette) colours() color.Palette {
+	palette = palette.orDefault()
+	mix := func(dead, alive uint8, level int) uint8 {
+		return uint8((int(dead)*(255-level) + int(alive)*level + 127) / 255)
+	}
+	colours := make(color.Palette, 256)
+	for level := range colours {
+		colours[level] = color.RGBA{
+			R: mix(palette.Dead.R, palette.Alive.R, level),
+			G: mix(palette.Dead.G, palette.Alive.G, level),
+			B: mix(palette.Dead.B, palette.Alive.B, level),
+			A: 255,
+		}
+	}
+	return colours
+}
+
+// level returns the grey level of a colour, from how far it lies from the dead colour towards the alive colour.
+func (palette Palette) level(c color.Color) uint8 {
+	palette = palette.orDefault()
+	r, g, b, _ := c.RGBA()
+	from := [3]int{int(palette.Dead.R), int(palette.Dead.G), int(palette.Dead.B)}
+	to := [3]int{int(palette.Alive.R), int(palette.Alive.G), int(palette.Alive.B)}
+	rgb := [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	dot, length := 0, 0
+	for i := range rgb {
+		dot += (rgb[i] - from[i]) * (to[i] - from[i])
+		length += (to[i] - from[i]) * (to[i] - from[i])
+	}
+	switch {
+	case dot <= 0:
+		return 0
+	case dot >= length:
+		return 255
+	}
+	return uint8((255*dot + length/2) / length)
+}
+
+// isPng reports whether the file at path is a PNG image, from its extension.
+func isPng(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".png"
+}
+
+// pngFormat returns the format of PNG images with the given scale and palette. A scale of 0 is taken as 1.
+func pngFormat(scale int, palette Palette) patternFormat {
+	if scale < 1 {
+		scale = 1
+	}
+	read := func(r io.Reader) (pattern, error) {
+		// The header is checked before the image is decoded, so that a huge image fails before it is allocated.
+		var header bytes.Buffer
+		p, err := readPngHeader(io.TeeReader(r, &header), scale)
+		if err != nil {
+			return p, err
+		}
+		img, err := png.Decode(io.MultiReader(&header, r))
+		if err != nil {
+			return p, err
+		}
+		bounds := img.Bounds()
+		p.Cells = newField(p.Height, p.Width)
+		for y, row := range p.Cells {
+			for x := range row {
+				row[x] = palette.level(img.At(bounds.Min.X+x*scale+scale/2, bounds.Min.Y+y*scale+scale/2))
+			}
+		}
+		return p, nil
+	}
+	write := func(w io.Writer, cells [][]uint8, rule Rule) error {
+		return png.Encode(w, scaledImage(cells, scale, palette))
+	}
+	return patternFormat{read, write}
+}
+
+// scaledImage draws the cells as an image in the colours of the palette, with each cell a square of scale x scale pixels.
+func scaledImage(cells [][]uint8, scale int, palette Palette) *image.Paletted {
+	height, width := len(cells), 0
+	if height > 0 {
+		width = len(cells[0])
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), palette.colours())
+	for y, row := range cells {
+		line := img.Pix[y*scale*img.Stride : y*scale*img.Stride+width*scale]
+		for x, cell := range row {
+			for i := 0; i < scale; i++ {
+				line[x*scale+i] = cell
+			}
+		}
+		for i := 1; i < scale; i++ {
+			copy(img.Pix[(y*scale+i)*img.Stride:], line)
+		}
+	}
+	return img
+}
+
+// readPngHeader returns the size in cells of the PNG image read from r.
+func readPngHeader(r io.Reader, scale int) (pattern, error) {
+	var p pattern
+	if scale < 1 {
+		scale = 1
+	}
+	config, err := png.DecodeConfig(r)
+	if err != nil {
+		return p, err
+	}
+	if config.Width%scale != 0 || config.Height%scale != 0 {
+		return p, fmt.Errorf("%vx%v image is not made of %vx%v cells", config.Width, config.Height, scale, scale)
+	}
+	p.Width, p.Height = config.Width/scale, config.Height/scale
+	return p, checkPatternSize(p.Width, p.Height)
+}
@@ -0,0 +1,204 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Images are read as PBM or PGM files in either their plain (P1, P2) or raw (P4, P5) forms, with comments
+// in the header and any maxval up to 65535. Grey levels are scaled to 0-255, so a raw PGM with a maxval of 255
+// is read unchanged, keeping the dying states of Generations rules. In PBM files 1 is black, which is read as alive.
+// Images are always written as raw PGM files with a maxval of 255.
+
+// pnmHeader is the header of a PBM or PGM image. The maxval of a PBM image is 1.
+type pnmHeader struct {
+	magic         string
+	width, height int
+	maxval        int
+}
+
+// readPnmToken reads the next token of the header of an image, skipping whitespace and comments.
+// The whitespace character ending the token is read as well.
+func readPnmToken(in *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		c, err := in.ReadByte()
+		if err == io.EOF && len(token) > 0 {
+			return string(token), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case c == '#' && len(token) == 0:
+			if _, err := in.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case isPnmSpace(c) && len(token) == 0:
+		case isPnmSpace(c):
+			return string(token), nil
+		default:
+			token = append(token, c)
+		}
+	}
+}
+
+func isPnmSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// readPnmNumber reads a positive number from the header of an image.
+func readPnmNumber(in *bufio.Reader, name string, max int) (int, error) {
+	token, err := readPnmToken(in)
+	if err != nil {
+		return 0, fmt.Errorf("reading %v: %v", name, err)
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n <= 0 || n > max {
+		return 0, fmt.Errorf("invalid %v %q", name, token)
+	}
+	return n, nil
+}
+
+// readPnmHeader reads the header of a PBM or PGM image, up to the single whitespace character before the pixels.
+func readPnmHeader(in *bufio.Reader) (pnmHeader, error) {
+	var header pnmHeader
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(in, magic); err != nil {
+		return header, errors.New("not a pbm or pgm file: file is empty")
+	}
+	header.magic = string(magic)
+	switch header.magic {
+	case "P1", "P2", "P4", "P5":
+	default:
+		return header, fmt.Errorf("not a pbm or pgm file: magic number %q is not P1, P2, P4 or P5", magic)
+	}
+	var err error
+	if header.width, err = readPnmNumber(in, "width", 1<<24); err != nil {
+		return header, err
+	}
+	if header.height, err = readPnmNumber(in, "height", 1<<24); err != nil {
+		return header, err
+	}
+	if err := checkPatternSize(header.width, header.height); err != nil {
+		return header, err
+	}
+	header.maxval = 1
+	if header.magic == "P2" || header.magic == "P5" {
+		if header.maxval, err = readPnmNumber(in, "maxval", 65535); err != nil {
+			return header, err
+		}
+	}
+	return header, nil
+}
+
+// readPnm reads a PBM or PGM image. Rows are allocated as they are read, so that an image ending early fails cheaply.
+func readPnm(r io.Reader) (pattern, error) {
+	var p pattern
+	in := bufio.NewReader(r)
+	header, err := readPnmHeader(in)
+	if err != nil {
+		return p, err
+	}
+	p.Width, p.Height = header.width, header.height
+
+	level := func(value int) (uint8, error) {
+		if value > header.maxval {
+			return 0, fmt.Errorf("pixel value %v is above the maxval of %v", value, header.maxval)
+		}
+		if header.magic == "P1" || header.magic == "P4" {
+			return uint8(255 * value), nil
+		}
+		return uint8((value*255 + header.maxval/2) / header.maxval), nil
+	}
+
+	var row []byte
+	switch header.magic {
+	case "P4":
+		row = make([]byte, (p.Width+7)/8)
+	case "P5":
+		row = make([]byte, p.Width)
+		if header.maxval > 255 {
+			row = make([]byte, 2*p.Width)
+		}
+	}
+
+	for y := 0; y < p.Height; y++ {
+		if row != nil {
+			if _, err := io.ReadFull(in, row); err != nil {
+				return p, fmt.Errorf("image data ends in row %v of %v", y+1, p.Height)
+			}
+		}
+		p.Cells = append(p.Cells, make([]uint8, p.Width))
+		for x := 0; x < p.Width; x++ {
+			var value int
+			switch header.magic {
+			case "P1":
+				value, err = readPbmDigit(in)
+			case "P2":
+				var token string
+				token, err = readPnmToken(in)
+				if err == nil {
+					value, err = strconv.Atoi(token)
+					if err != nil {
+						err = fmt.Errorf("invalid pixel value %q", token)
+					}
+				}
+			case "P4":
+				value = int(row[x/8]>>(7-uint(x%8))) & 1
+			case "P5":
+				if header.maxval > 255 {
+					value = int(row[2*x])<<8 | int(row[2*x+1])
+				} else {
+					value = int(row[x])
+				}
+			}
+			if err == io.EOF {
+				err = fmt.Errorf("image data ends in row %v of %v", y+1, p.Height)
+			}
+			if err == nil {
+				p.Cells[y][x], err = level(value)
+			}
+			if err != nil {
+				return p, fmt.Errorf("pixel %v,%v: %v", x, y, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// readPbmDigit reads the next pixel of a plain PBM image, which is a 0 or a 1 that need not be followed by whitespace.
+func readPbmDigit(in *bufio.Reader) (int, error) {
+	for {
+		c, err := in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c == '0' || c == '1':
+			return int(c - '0'), nil
+		case c == '#':
+			if _, err := in.ReadString('\n'); err != nil {
+				return 0, err
+			}
+		case !isPnmSpace(c):
+			return 0, fmt.Errorf("invalid pixel value %q", c)
+		}
+	}
+}
+
+// threshold makes cells at or above the given grey level alive and all others dead.
+func (p pattern) threshold(level int) {
+	for _, row := range p.Cells {
+		for x, cell := range row {
+			if int(cell) >= level {
+				row[x] = 255
+			} else {
+				row[x] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gol
+
+import (
+	"errors"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// WorkerMode selects how the worker threads share the world between them.
+type WorkerMode int
+
+const (
+	// HaloWorkers gives every worker its own tile of the world for the whole run, laid out as in Params.Tiles.
+	// Each turn the workers exchange only their outer cells with the neighbouring tiles, and the distributor
+	// collects the full world only when it needs it. CrossSurface worlds use PoolWorkers instead, as the halo cells
+	// on their left and right edges come from the opposite side of the world.
+	HaloWorkers WorkerMode = iota
+	// PoolWorkers keeps one long-lived worker per thread, all reading a shared double-buffered world.
+	PoolWorkers
+	// SpawnWorkers starts new worker goroutines every turn. It is only useful for benchmarking.
+	SpawnWorkers
+)
+
+var workerModeNames = []string{"halo", "pool", "spawn"}
+
+// String returns the name of the worker mode, as accepted by Set.
+func (mode WorkerMode) String() string {
+	if mode < 0 || int(mode) >= len(workerModeNames) {
+		return "Incorrect WorkerMode"
+	}
+	return workerModeNames[mode]
+}
+
+// Set parses the name of a worker mode, so that a WorkerMode can be used as a flag.Value.
+func (mode *WorkerMode) Set(s string) error {
+	for i, name := range workerModeNames {
+		if s == name {
+			*mode = WorkerMode(i)
+			return nil
+		}
+	}
+	return errors.New("unknown worker mode " + s)
+}
+
+// workerPool is a set of long-lived worker goroutines, one per thread, each owning a fixed strip of rows.
+// Every turn the distributor hands each worker the turn to compute and collects the cells that flipped.
+// The world is double-buffered: workers read the current turn and write their strip of the next one,
+// and the distributor swaps the buffers once every worker has finished.
+type workerPool struct {
+	turnCh    []chan int
+	flippedCh []chan []util.Cell
+}
+
+// startPool starts the worker goroutines and allocates the buffer for the next turn.
+func (world *World) startPool() {
+	if world.backend == BitBackend {
+		world.nextBits = make([][]uint64, world.height)
+		for y := range world.nextBits {
+			world.nextBits[y] = make([]uint64, wordsPerRow(world.width))
+		}
+	} else {
+		world.next = newField(world.height, world.width)
+	}
+
+	pool := &workerPool{
+		turnCh:    make([]chan int, world.threads),
+		flippedCh: make([]chan []util.Cell, world.threads),
+	}
+	for w := 0; w < world.threads; w++ {
+		pool.turnCh[w] = make(chan int)
+		pool.flippedCh[w] = make(chan []util.Cell)
+		start, end := world.regionBounds(w)
+		go world.runWorker(start, end, pool.turnCh[w], pool.flippedCh[w])
+	}
+	world.pool = pool
+}
+
+// stopPool stops the worker goroutines.
+func (world *World) stopPool() {
+	for _, turnCh := range world.pool.turnCh {
+		close(turnCh)
+	}
+	world.pool = nil
+}
+
+// runWorker computes rows [start, end) of the next turn whenever it is sent a turn.
+// The slice of flipped cells it sends back is reused on the following turn.
+func (world *World) runWorker(start, end int, turnCh <-chan int, flippedCh chan<- []util.Cell) {
+	var window [3][]uint8
+	for i := range window {
+		window[i] = make([]uint8, world.width+2)
+	}
+	flipped := []util.Cell{}
+	for range turnCh {
+		if world.backend == BitBackend {
+			flipped = world.updatePackedRows(start, end, flipped[:0])
+		} else {
+			flipped = world.updateRows(start, end, &window, flipped[:0])
+		}
+		flippedCh <- flipped
+	}
+}
+
+// padRow copies row y of the world, which may lie just outside the world, into row together with its halo cells.
+func (world *World) padRow(y int, row []uint8) {
+	if y >= 0 && y < world.height {
+		copy(row[1:], world.field[y])
+	} else {
+		for x := 0; x < world.width; x++ {
+			row[x+1] = world.cell(x, y)
+		}
+	}
+	row[0] = world.cell(-1, y)
+	row[world.width+1] = world.cell(world.width, y)
+}
+
+// updateRows computes rows [start, end) of the next turn into world.next and appends the cells that flipped.
+// window holds the padded rows above, at and below the row being computed.
+func (world *World) updateRows(start, end int, window *[3][]uint8, flipped []util.Cell) []util.Cell {
+	if start == end {
+		return flipped
+	}
+	world.padRow(start-1, window[0])
+	world.padRow(start, window[1])
+	for y := start; y < end; y++ {
+		world.padRow(y+1, window[2])
+		up, row, down := window[0], window[1], window[2]
+		next := world.next[y]
+		for x := 1; x <= world.width; x++ {
+			aliveNeighbours := 0
+			for _, neighbour := range [8]uint8{
+				up[x-1], up[x], up[x+1],
+				row[x-1], row[x+1],
+				down[x-1], down[x], down[x+1],
+			} {
+				if neighbour == 255 {
+					aliveNeighbours++
+				}
+			}
+			nextCell := world.rule.next(row[x], aliveNeighbours)
+			if nextCell != row[x] {
+				flipped = append(flipped, util.Cell{X: x - 1, Y: y})
+			}
+			next[x-1] = nextCell
+		}
+		window[0], window[1], window[2] = row, down, up
+	}
+	return flipped
+}
+
+// updatePackedRows is the BitBackend equivalent of updateRows, computing into world.nextBits.
+func (world *World) updatePackedRows(start, end int, flipped []util.Cell) []util.Cell {
+	if start == end {
+		return flipped
+	}
+	up, row := world.packedHaloRow(start-1), world.packedHaloRow(start)
+	for y := start; y < end; y++ {
+		down := world.packedHaloRow(y + 1)
+		flipped = nextPackedRow(world.rule, world.width, y, up, row, down, world.nextBits[y], flipped)
+		up, row = row, down
+	}
+	return flipped
+}
+
+// updatePooledWorld is the equivalent of updateWorld using the worker pool.
+func (world *World) updatePooledWorld(turn int, c distributorChannels) {
+	for _, turnCh := range world.pool.turnCh {
+		turnCh <- turn
+	}
+	flipped := make([][]util.Cell, world.threads)
+	for w, flippedCh := range world.pool.flippedCh {
+		flipped[w] = <-flippedCh
+	}
+
+	if world.backend == BitBackend {
+		world.bits, world.nextBits = world.nextBits, world.bits
+	} else {
+		world.field, world.next = world.next, world.field
+	}
+
+	for _, cells := range flipped {
+		for _, cell := range cells {
+			if world.backend == BitBackend {
+				world.field[cell.Y][cell.X] = 0
+				if world.bits[cell.Y][cell.X/64]&(1<<uint(cell.X%64)) != 0 {
+					world.field[cell.Y][cell.X] = 255
+				}
+			}
+			c.events <- CellFlipped{
+				CompletedTurns: turn,
+				Cell:           cell,
+				Value:          world.field[cell.Y][cell.X],
+			}
+		}
+	}
+}
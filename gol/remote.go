@@ -0,0 +1,813 @@
+package gol
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// In distributed mode a broker process owns the world and runs the distributor, while separately started
+// worker processes compute strips of rows for it. Workers register with the broker when they start, and can
+// leave again by unregistering. The world is split again between the workers at the start of every turn,
+// and a WorkersChanged event is sent when they are different from the turn before.
+// The controller calls Run with Params.Broker set; it starts the simulation on the broker, or attaches to the
+// one already running there, relays the broker's events to its events channel and forwards its key presses
+// to the broker. Pressing 'q' only detaches the controller, leaving the simulation running on the broker,
+// while 'k' finishes the simulation and shuts down the broker and its workers.
+// Images are read and written by the broker.
+// The broker sends each worker a heartbeat every heartbeatInterval. A worker that does not answer one within
+// heartbeatTimeout, drops its connection or takes longer than stepTimeout over a strip is dropped, and its strip
+// is computed again by the workers that are left, or by the broker itself once there are none.
+
+const (
+	// maxEventBatch is the largest number of events returned by one call to Broker.Events.
+	maxEventBatch = 4096
+	// eventBatchDelay is the longest time an event is held back by Broker.Events.
+	eventBatchDelay = 100 * time.Millisecond
+	// heartbeatInterval is the time between the heartbeats the broker sends to each worker.
+	heartbeatInterval = time.Second
+	// heartbeatTimeout is the longest time a worker may take to answer a heartbeat.
+	heartbeatTimeout = 5 * time.Second
+	// stepTimeout is the longest time a worker may take to compute a strip.
+	stepTimeout = 30 * time.Second
+)
+
+func init() {
+	// Events are sent to the controller as interface values, so gob needs to know every type of event.
+	gob.Register(AliveCellsCount{})
+	gob.Register(ImageOutputComplete{})
+	gob.Register(CheckpointComplete{})
+	gob.Register(StateChange{})
+	gob.Register(CellFlipped{})
+	gob.Register(TurnComplete{})
+	gob.Register(FinalTurnComplete{})
+	gob.Register(TilesSkipped{})
+	gob.Register(WorkersChanged{})
+	gob.Register(Error{})
+	gob.Register(remoteError(""))
+}
+
+// remoteError is the error of an Error event sent to the controller, which keeps only the message of the error.
+type remoteError string
+
+func (err remoteError) Error() string {
+	return string(err)
+}
+
+// StepRequest asks a worker to compute the next turn of a strip of rows.
+type StepRequest struct {
+	// Field holds the rows of the strip surrounded by a halo of one cell on every side.
+	Field [][]uint8
+	// Start is the first row of the strip in the world.
+	Start int
+	Rule  Rule
+}
+
+// StepResponse holds the next turn of the strip and the cells that flipped.
+type StepResponse struct {
+	Field   [][]uint8
+	Flipped []util.Cell
+}
+
+// RegisterRequest registers a worker listening on Address with the broker.
+type RegisterRequest struct {
+	Address string
+}
+
+type RegisterResponse struct{}
+
+// UnregisterRequest removes the worker listening on Address from the broker.
+type UnregisterRequest struct {
+	Address string
+}
+
+type UnregisterResponse struct{}
+
+// RunRequest starts a simulation on the broker.
+type RunRequest struct {
+	Params Params
+}
+
+// AttachRequest attaches a controller to the simulation running on the broker.
+type AttachRequest struct{}
+
+// RunResponse identifies the controller's session with the broker, and holds the parameters of the simulation.
+type RunResponse struct {
+	ID     int
+	Params Params
+}
+
+// EventsRequest asks the broker for the next events of a session.
+type EventsRequest struct {
+	ID int
+}
+
+// EventsResponse holds the next events of a session. Done is set once the simulation has sent its last event,
+// or the controller has detached.
+type EventsResponse struct {
+	Events []Event
+	Done   bool
+}
+
+// KeyRequest forwards a key press to the simulation of a session.
+type KeyRequest struct {
+	ID  int
+	Key rune
+}
+
+type KeyResponse struct{}
+
+// DetachRequest ends a session, leaving its simulation running.
+type DetachRequest struct {
+	ID int
+}
+
+type DetachResponse struct{}
+
+type HeartbeatRequest struct{}
+
+type HeartbeatResponse struct{}
+
+type ShutdownRequest struct{}
+
+type ShutdownResponse struct{}
+
+// workerServer computes strips for a broker.
+type workerServer struct {
+	listener net.Listener
+}
+
+// Step computes the next turn of a strip.
+func (w *workerServer) Step(req StepRequest, res *StepResponse) error {
+	region := Region{
+		field:  req.Field,
+		start:  req.Start,
+		height: len(req.Field) - 2,
+		width:  len(req.Field[0]) - 2,
+		rule:   req.Rule,
+	}
+	res.Field, res.Flipped = region.next()
+	return nil
+}
+
+// Heartbeat tells the broker that the worker is still running.
+func (w *workerServer) Heartbeat(req HeartbeatRequest, res *HeartbeatResponse) error {
+	return nil
+}
+
+// Shutdown stops the worker from accepting further requests, so that ServeWorker returns.
+func (w *workerServer) Shutdown(req ShutdownRequest, res *ShutdownResponse) error {
+	return w.listener.Close()
+}
+
+// ServeWorker registers a worker with the broker at brokerAddress, telling it to connect to address,
+// and then serves the broker's requests on listener until the broker shuts the worker down or leave is closed.
+// On leaving, the worker unregisters and waits for the broker to finish the turn in progress before returning.
+func ServeWorker(listener net.Listener, address, brokerAddress string, leave <-chan bool) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Worker", &workerServer{listener: listener}); err != nil {
+		return err
+	}
+	client, err := rpc.Dial("tcp", brokerAddress)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	// The broker connects back to the worker before replying, which succeeds as soon as the listener is open.
+	if err := client.Call("Broker.Register", RegisterRequest{Address: address}, &RegisterResponse{}); err != nil {
+		return err
+	}
+	fmt.Println("Registered with broker", brokerAddress)
+	served := make(chan bool)
+	left := make(chan error, 1)
+	go func() {
+		select {
+		case <-leave:
+			left <- client.Call("Broker.Unregister", UnregisterRequest{Address: address}, &UnregisterResponse{})
+			_ = listener.Close()
+		case <-served:
+		}
+	}()
+	server.Accept(listener)
+	close(served)
+	select {
+	case err := <-left:
+		return err
+	default:
+		return nil
+	}
+}
+
+// remoteWorker is the broker's connection to a worker process.
+type remoteWorker struct {
+	address string
+	client  *rpc.Client
+	// lost is closed when the worker is dropped.
+	lost chan bool
+}
+
+// remoteWorkers is the set of worker processes registered with a broker that are still running.
+type remoteWorkers struct {
+	// turn is held while a turn is computed, so that workers only leave between turns.
+	turn    sync.Mutex
+	mutex   sync.Mutex
+	workers []*remoteWorker
+	// version counts the changes to workers.
+	version int
+}
+
+// add adds a worker to the set and starts sending it heartbeats.
+func (workers *remoteWorkers) add(address string, client *rpc.Client) {
+	w := &remoteWorker{address: address, client: client, lost: make(chan bool)}
+	workers.mutex.Lock()
+	workers.workers = append(workers.workers, w)
+	workers.version++
+	workers.mutex.Unlock()
+	go workers.monitor(w)
+}
+
+// list returns the workers that are still running.
+func (workers *remoteWorkers) list() []*remoteWorker {
+	list, _ := workers.members()
+	return list
+}
+
+// members returns the workers that are still running and the version of the set they belong to.
+func (workers *remoteWorkers) members() ([]*remoteWorker, int) {
+	workers.mutex.Lock()
+	defer workers.mutex.Unlock()
+	return append([]*remoteWorker{}, workers.workers...), workers.version
+}
+
+// remove drops a worker from the set and closes the connection to it, failing the calls that are waiting on it.
+// It reports whether the worker was still in the set.
+func (workers *remoteWorkers) remove(w *remoteWorker) bool {
+	workers.mutex.Lock()
+	defer workers.mutex.Unlock()
+	for i, other := range workers.workers {
+		if other == w {
+			workers.workers = append(workers.workers[:i], workers.workers[i+1:]...)
+			workers.version++
+			close(w.lost)
+			_ = w.client.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// lose drops a worker that has failed.
+func (workers *remoteWorkers) lose(w *remoteWorker, err error) {
+	if workers.remove(w) {
+		fmt.Println("Worker lost from", w.address+":", err)
+	}
+}
+
+// leave drops the worker listening on address once the turn in progress is complete.
+func (workers *remoteWorkers) leave(address string) error {
+	workers.turn.Lock()
+	defer workers.turn.Unlock()
+	for _, w := range workers.list() {
+		if w.address == address && workers.remove(w) {
+			fmt.Println("Worker left from", address)
+			return nil
+		}
+	}
+	return fmt.Errorf("no worker is registered from %v", address)
+}
+
+// monitor sends heartbeats to a worker until it is dropped, and drops it if one is not answered in time.
+func (workers *remoteWorkers) monitor(w *remoteWorker) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.call("Worker.Heartbeat", HeartbeatRequest{}, &HeartbeatResponse{}, heartbeatTimeout); err != nil {
+				workers.lose(w, err)
+				return
+			}
+		case <-w.lost:
+			return
+		}
+	}
+}
+
+// call calls a method of the worker, failing if it does not reply within timeout.
+func (w *remoteWorker) call(method string, args interface{}, reply interface{}, timeout time.Duration) error {
+	call := w.client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-timer.C:
+		return fmt.Errorf("%v timed out after %v", method, timeout)
+	case <-w.lost:
+		return errors.New("worker lost")
+	}
+}
+
+// brokerRun is a simulation running on the broker. Its events are read as they are sent, whether or not
+// a controller is attached, and the cells they flip are recorded so that a controller that attaches later
+// can be sent the current board.
+type brokerRun struct {
+	params     Params
+	events     chan Event
+	keyPresses chan rune
+
+	mutex sync.Mutex
+	// board holds the cells after the number of turns completed, and flipped the events of the turn in progress.
+	board    [][]uint8
+	turns    int
+	flipped  []CellFlipped
+	attached *attachment
+	finished bool
+	// shutdown is set when 'k' has been pressed, so that the broker shuts down once the simulation finishes.
+	shutdown bool
+}
+
+// attachment is the connection between a simulation and the controller attached to it.
+type attachment struct {
+	id int
+	// pending holds the events that bring the controller up to date, sent before any others.
+	pending []Event
+	events  chan Event
+	// detached is closed when the controller detaches.
+	detached chan bool
+}
+
+// brokerServer runs simulations for controllers using the registered workers.
+// Only one simulation runs at a time: starting a new one quits the previous one.
+type brokerServer struct {
+	workers  *remoteWorkers
+	listener net.Listener
+	mutex    sync.Mutex
+	run      *brokerRun
+	lastID   int
+	// stopped is closed when the broker shuts down.
+	stopped  chan bool
+	stopOnce sync.Once
+}
+
+// Register connects the broker to a new worker.
+func (s *brokerServer) Register(req RegisterRequest, res *RegisterResponse) error {
+	client, err := rpc.Dial("tcp", req.Address)
+	if err != nil {
+		return err
+	}
+	s.workers.add(req.Address, client)
+	fmt.Println("Worker registered from", req.Address)
+	return nil
+}
+
+// Unregister removes a worker from the broker once the turn in progress is complete.
+func (s *brokerServer) Unregister(req UnregisterRequest, res *UnregisterResponse) error {
+	return s.workers.leave(req.Address)
+}
+
+// Run starts a new simulation and attaches the controller to it.
+// A simulation that is still running is sent 'q' so that it finishes.
+func (s *brokerServer) Run(req RunRequest, res *RunResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if previous := s.run; previous != nil {
+		select {
+		case previous.keyPresses <- 'q':
+		default:
+		}
+		previous.detach(0)
+	}
+	p := req.Params
+	p.Broker = ""
+	p.Attach = false
+	p.remote = s.workers
+	run := &brokerRun{
+		params:     p,
+		events:     make(chan Event),
+		keyPresses: make(chan rune, 10),
+		board:      newField(p.ImageHeight, p.ImageWidth),
+	}
+	s.run = run
+	s.lastID++
+	run.attach(s.lastID)
+	go run.record(s)
+	go Run(p, run.events, run.keyPresses)
+	res.ID, res.Params = s.lastID, req.Params
+	return nil
+}
+
+// Attach attaches the controller to the simulation that is running, detaching any other controller.
+func (s *brokerServer) Attach(req AttachRequest, res *RunResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.run == nil {
+		return errors.New("no simulation is running")
+	}
+	s.lastID++
+	if !s.run.attach(s.lastID) {
+		return errors.New("no simulation is running")
+	}
+	res.ID, res.Params = s.lastID, s.run.params
+	return nil
+}
+
+// session returns the simulation and attachment of a session, or nil if the session has ended.
+func (s *brokerServer) session(id int) (*brokerRun, *attachment) {
+	s.mutex.Lock()
+	run := s.run
+	s.mutex.Unlock()
+	if run == nil {
+		return nil, nil
+	}
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.attached == nil || run.attached.id != id {
+		return nil, nil
+	}
+	return run, run.attached
+}
+
+// Events waits for the next event of a session and returns it together with those that follow it,
+// up to the end of the turn. Events that are not followed by the end of a turn within eventBatchDelay,
+// such as those sent while paused, are returned without waiting for it.
+func (s *brokerServer) Events(req EventsRequest, res *EventsResponse) error {
+	run, a := s.session(req.ID)
+	if a == nil {
+		res.Done = true
+		return nil
+	}
+	// The pending events are built by attach under the lock of the run, and only handed over once.
+	run.mutex.Lock()
+	res.Events, a.pending = a.pending, nil
+	run.mutex.Unlock()
+	if res.Events != nil {
+		return nil
+	}
+	var event Event
+	ok := true
+	select {
+	case event, ok = <-a.events:
+	case <-a.detached:
+		ok = false
+	}
+	timeout := time.After(eventBatchDelay)
+	for ok {
+		res.Events = append(res.Events, event)
+		switch event.(type) {
+		case TurnComplete, FinalTurnComplete:
+			return nil
+		}
+		if len(res.Events) == maxEventBatch {
+			return nil
+		}
+		select {
+		case event, ok = <-a.events:
+		case <-timeout:
+			return nil
+		}
+	}
+	res.Done = true
+	return nil
+}
+
+// Key forwards a key press to the simulation of a session.
+func (s *brokerServer) Key(req KeyRequest, res *KeyResponse) error {
+	run, _ := s.session(req.ID)
+	if run == nil {
+		return errors.New("session has ended")
+	}
+	if req.Key == 'k' {
+		run.mutex.Lock()
+		run.shutdown = true
+		run.mutex.Unlock()
+	}
+	select {
+	case run.keyPresses <- req.Key:
+	default:
+	}
+	return nil
+}
+
+// Detach ends a session, leaving its simulation running.
+func (s *brokerServer) Detach(req DetachRequest, res *DetachResponse) error {
+	if run, _ := s.session(req.ID); run != nil {
+		run.detach(req.ID)
+	}
+	return nil
+}
+
+// Shutdown shuts down the workers and then the broker, so that ServeBroker returns.
+func (s *brokerServer) Shutdown(req ShutdownRequest, res *ShutdownResponse) error {
+	s.stopOnce.Do(func() {
+		for _, w := range s.workers.list() {
+			_ = w.call("Worker.Shutdown", ShutdownRequest{}, &ShutdownResponse{}, heartbeatTimeout)
+			s.workers.remove(w)
+		}
+		_ = s.listener.Close()
+		close(s.stopped)
+	})
+	return nil
+}
+
+// attach attaches a new controller to the simulation, detaching any other one, and reports whether the
+// simulation is still running. The controller is first sent the cells of the board after the last completed turn,
+// followed by the cells that have flipped so far in the turn in progress.
+func (run *brokerRun) attach(id int) bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.finished {
+		return false
+	}
+	if run.attached != nil {
+		close(run.attached.detached)
+	}
+	a := &attachment{
+		id:       id,
+		pending:  []Event{},
+		events:   make(chan Event),
+		detached: make(chan bool),
+	}
+	if run.turns > 0 {
+		for y, row := range run.board {
+			for x, value := range row {
+				if value != 0 {
+					a.pending = append(a.pending, CellFlipped{
+						CompletedTurns: run.turns - 1,
+						Cell:           util.Cell{X: x, Y: y},
+						Value:          value,
+					})
+				}
+			}
+		}
+		a.pending = append(a.pending, TurnComplete{CompletedTurns: run.turns - 1})
+	}
+	for _, flipped := range run.flipped {
+		a.pending = append(a.pending, flipped)
+	}
+	run.attached = a
+	return true
+}
+
+// detach detaches the controller of the given session, or any controller if id is 0.
+func (run *brokerRun) detach(id int) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.attached != nil && (id == 0 || run.attached.id == id) {
+		close(run.attached.detached)
+		run.attached = nil
+	}
+}
+
+// record reads the events of the simulation, recording the board and passing them on to the attached controller.
+// If 'k' was pressed the attached controller shuts the broker down once it has received the last event,
+// but the broker shuts itself down if there is no controller or it does not do so in time.
+func (run *brokerRun) record(s *brokerServer) {
+	for event := range run.events {
+		run.mutex.Lock()
+		switch e := event.(type) {
+		case CellFlipped:
+			run.flipped = append(run.flipped, e)
+		case TurnComplete:
+			for _, flipped := range run.flipped {
+				run.board[flipped.Cell.Y][flipped.Cell.X] = flipped.Value
+			}
+			run.flipped = run.flipped[:0]
+			run.turns = e.CompletedTurns + 1
+		case Error:
+			e.Err = remoteError(e.Err.Error())
+			event = e
+		}
+		a := run.attached
+		run.mutex.Unlock()
+		if a != nil {
+			select {
+			case a.events <- event:
+			case <-a.detached:
+			}
+		}
+	}
+
+	run.mutex.Lock()
+	run.finished = true
+	a := run.attached
+	shutdown := run.shutdown
+	run.mutex.Unlock()
+	if a != nil {
+		close(a.events)
+	}
+	if shutdown {
+		if a != nil {
+			select {
+			case <-s.stopped:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		_ = s.Shutdown(ShutdownRequest{}, &ShutdownResponse{})
+	}
+}
+
+// ServeBroker serves controllers and workers on listener until it is closed or a controller presses 'k'.
+func ServeBroker(listener net.Listener) error {
+	server := rpc.NewServer()
+	broker := &brokerServer{
+		workers:  &remoteWorkers{},
+		listener: listener,
+		stopped:  make(chan bool),
+	}
+	if err := server.RegisterName("Broker", broker); err != nil {
+		return err
+	}
+	server.Accept(listener)
+	return nil
+}
+
+// runController runs a simulation on the broker at p.Broker, or attaches to the one running there if p.Attach is set,
+// relaying its events and forwarding key presses. 'q' detaches from the simulation instead of being forwarded.
+// It returns the error of an Error event from the broker, or of losing the connection to it.
+// When ctx is cancelled the simulation on the broker is sent 'q', so that it finishes, and ctx.Err() is returned.
+func runController(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune) error {
+	client, err := rpc.Dial("tcp", p.Broker)
+	if err != nil {
+		return fail(0, err, events)
+	}
+	defer client.Close()
+	var session RunResponse
+	if p.Attach {
+		err = client.Call("Broker.Attach", AttachRequest{}, &session)
+		if err == nil && (session.Params.ImageWidth != p.ImageWidth || session.Params.ImageHeight != p.ImageHeight) {
+			err = fmt.Errorf("the simulation on the broker is %dx%d, not %dx%d",
+				session.Params.ImageWidth, session.Params.ImageHeight, p.ImageWidth, p.ImageHeight)
+		}
+	} else {
+		err = client.Call("Broker.Run", RunRequest{Params: p}, &session)
+	}
+	if err != nil {
+		return fail(0, err, events)
+	}
+
+	done := make(chan bool)
+	killed := make(chan bool, 1)
+	go func() {
+		cancelled := ctx.Done()
+		for {
+			select {
+			case <-cancelled:
+				cancelled = nil
+				_ = client.Call("Broker.Key", KeyRequest{ID: session.ID, Key: 'q'}, &KeyResponse{})
+			case key := <-keyPresses:
+				switch key {
+				case 'q':
+					_ = client.Call("Broker.Detach", DetachRequest{ID: session.ID}, &DetachResponse{})
+					continue
+				case 'k':
+					select {
+					case killed <- true:
+					default:
+					}
+				}
+				_ = client.Call("Broker.Key", KeyRequest{ID: session.ID, Key: key}, &KeyResponse{})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	turns := 0
+	for {
+		var response EventsResponse
+		if err = client.Call("Broker.Events", EventsRequest{ID: session.ID}, &response); err != nil {
+			close(done)
+			return fail(turns, fmt.Errorf("lost the broker: %v", err), events)
+		}
+		for _, event := range response.Events {
+			if e, ok := event.(Error); ok {
+				err = e.Err
+			}
+			turns = event.GetCompletedTurns()
+			events <- event
+		}
+		if response.Done {
+			break
+		}
+	}
+	close(done)
+	select {
+	case <-killed:
+		// The broker exits as soon as it has shut down, so it may not reply.
+		_ = client.Call("Broker.Shutdown", ShutdownRequest{}, &ShutdownResponse{})
+	default:
+	}
+	close(events)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// startRemote makes the world use the broker's workers. Workers are sent one byte per cell,
+// so the world always uses ByteBackend.
+func (world *World) startRemote(workers *remoteWorkers) {
+	world.remote = workers
+	world.remoteVersion = -1
+	world.backend = ByteBackend
+	world.bits = nil
+}
+
+// stepResult is the reply of a worker to a StepRequest for a strip.
+type stepResult struct {
+	strip    int
+	worker   *remoteWorker
+	response *StepResponse
+	err      error
+}
+
+// updateRemoteWorld is the equivalent of updateWorld using the broker's workers.
+// The world is split into one strip per worker. The strips of workers that fail are handed out again to
+// the workers that are left until every strip has been computed, and the broker computes them itself
+// while there are no workers.
+func (world *World) updateRemoteWorld(turn int, c distributorChannels) {
+	world.remote.turn.Lock()
+	defer world.remote.turn.Unlock()
+	workers, version := world.remote.members()
+	if version != world.remoteVersion {
+		world.remoteVersion = version
+		c.events <- WorkersChanged{CompletedTurns: turn, Workers: len(workers)}
+	}
+	strips := len(workers)
+	if strips > world.height {
+		strips = world.height
+	}
+	if strips == 0 {
+		strips = 1
+	}
+	responses := make([]*StepResponse, strips)
+	pending := make([]int, strips)
+	for i := range pending {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		workers := world.remote.list()
+		if len(workers) == 0 {
+			for _, i := range pending {
+				start, end := splitRows(world.height, strips, i)
+				region := world.makeRegion(start, end)
+				field, flipped := region.next()
+				responses[i] = &StepResponse{Field: field, Flipped: flipped}
+			}
+			break
+		}
+		results := make(chan stepResult, len(pending))
+		for j, i := range pending {
+			go func(strip int, w *remoteWorker) {
+				start, end := splitRows(world.height, strips, strip)
+				region := world.makeRegion(start, end)
+				response := &StepResponse{}
+				err := w.call("Worker.Step", StepRequest{
+					Field: region.field,
+					Start: start,
+					Rule:  world.rule,
+				}, response, stepTimeout)
+				results <- stepResult{strip: strip, worker: w, response: response, err: err}
+			}(i, workers[j%len(workers)])
+		}
+		failed := []int{}
+		for range pending {
+			result := <-results
+			if result.err != nil {
+				world.remote.lose(result.worker, result.err)
+				failed = append(failed, result.strip)
+			} else {
+				responses[result.strip] = result.response
+			}
+		}
+		pending = failed
+	}
+
+	field := make([][]uint8, 0, world.height)
+	for _, response := range responses {
+		field = append(field, response.Field...)
+	}
+	world.field = field
+	for _, response := range responses {
+		sendFlipped(turn, field, response.Flipped, c)
+	}
+}
+
+// sendFlipped sends a CellFlipped event for each of the cells, which must already hold their new value in field.
+func sendFlipped(turn int, field [][]uint8, flipped []util.Cell, c distributorChannels) {
+	for _, cell := range flipped {
+		c.events <- CellFlipped{
+			CompletedTurns: turn,
+			Cell:           cell,
+			Value:          field[cell.Y][cell.X],
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Patterns in the run length encoded format used by Golly and LifeWiki start with a header line giving
+// their size and optionally their rule, followed by runs of cells ended by '!':
+//
+//	#N Glider
+//	x = 3, y = 3, rule = B3/S23
+//	bob$2bo$3o!
+//
+// 'b' is a dead cell, 'o' an alive one and '$' the end of a row, each optionally preceded by a count.
+// Patterns of Generations rules use '.' for dead cells, 'A' for alive ones and 'B', 'C' and so on for
+// the dying states. Lines starting with '#' are comments.
+
+// maxRLELine is the longest line written to an RLE file.
+const maxRLELine = 70
+
+// pattern is a pattern read from a file, with the grey levels of its cells.
+// Rule is the zero Rule if the file does not give one. Bounded patterns give the size of the world,
+// like images, rather than being centred in it.
+type pattern struct {
+	Width, Height int
+	Rule          Rule
+	Cells         [][]uint8
+	Bounded       bool
+}
+
+// readRLEHeader reads the comments and header line of an RLE pattern.
+func readRLEHeader(in *bufio.Reader) (pattern, error) {
+	var p pattern
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return p, errors.New("RLE pattern has no header")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Split(line, ",") {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return p, fmt.Errorf("invalid RLE header %q", line)
+			}
+			value := strings.TrimSpace(parts[1])
+			switch strings.TrimSpace(parts[0]) {
+			case "x":
+				p.Width, err = strconv.Atoi(value)
+			case "y":
+				p.Height, err = strconv.Atoi(value)
+			case "rule":
+				p.Rule, err = parseRLERule(value)
+			}
+			if err != nil {
+				return p, fmt.Errorf("invalid RLE header %q: %v", line, err)
+			}
+		}
+		if p.Width <= 0 || p.Height <= 0 {
+			return p, fmt.Errorf("RLE header %q does not give the size of the pattern", line)
+		}
+		return p, checkPatternSize(p.Width, p.Height)
+	}
+}
+
+// parseRLERule parses the rule of an RLE header, which is in B/S notation or in the older S/B notation, such as 23/3.
+func parseRLERule(s string) (Rule, error) {
+	rule, err := ParseRule(s)
+	if err == nil {
+		return rule, nil
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) == 2 && strings.Trim(parts[0]+parts[1], "012345678") == "" {
+		return ParseRule("B" + parts[1] + "/S" + parts[0])
+	}
+	return rule, err
+}
+
+// readRLE reads an RLE pattern.
+func readRLE(r io.Reader) (pattern, error) {
+	in := bufio.NewReader(r)
+	p, err := readRLEHeader(in)
+	if err != nil {
+		return p, err
+	}
+	states := p.Rule.orDefault().States
+	p.Cells = newField(p.Height, p.Width)
+	x, y, count := 0, 0, 0
+	for {
+		c, _, err := in.ReadRune()
+		if err != nil {
+			return p, errors.New("RLE pattern is not ended by !")
+		}
+		switch {
+		case c >= '0' && c <= '9':
+			count = count*10 + int(c-'0')
+			if count > p.Width && count > p.Height {
+				return p, fmt.Errorf("run count in RLE pattern is larger than its size of %vx%v", p.Width, p.Height)
+			}
+			continue
+		case unicode.IsSpace(c):
+			continue
+		case c == '!':
+			return p, nil
+		}
+		if count == 0 {
+			count = 1
+		}
+		switch {
+		case c == '$':
+			x, y = 0, y+count
+		case c == 'b' || c == '.' || c == 'o' || c >= 'A' && c <= 'X':
+			var value uint8
+			switch {
+			case c == 'o':
+				value = 255
+			case c >= 'A':
+				value = stateLevel(int(c-'A')+1, states)
+			}
+			if x+count > p.Width || y >= p.Height {
+				return p, fmt.Errorf("RLE pattern does not fit in its size of %vx%v", p.Width, p.Height)
+			}
+			for i := 0; i < count; i++ {
+				p.Cells[y][x+i] = value
+			}
+			x += count
+		default:
+			return p, fmt.Errorf("invalid character %q in RLE pattern", c)
+		}
+		count = 0
+	}
+}
+
+// stateLevel returns the grey level of a state of a Generations rule with the given number of states,
+// where state 1 is alive and the following states are dying.
+func stateLevel(state, states int) uint8 {
+	if states <= 2 {
+		if state == 1 {
+			return 255
+		}
+		return 0
+	}
+	if state >= states {
+		return 0
+	}
+	return uint8(255 * (states - state) / (states - 1))
+}
+
+// levelState returns the state of a Generations rule with the given number of states for a grey level.
+func levelState(level uint8, states int) int {
+	if level == 0 {
+		return 0
+	}
+	if states <= 2 {
+		return 1
+	}
+	steps := states - 1
+	return states - (int(level)*steps+254)/255
+}
+
+// writeRLE writes the cells of a world in the RLE format.
+func writeRLE(w io.Writer, cells [][]uint8, rule Rule) error {
+	rule = rule.orDefault()
+	out := bufio.NewWriter(w)
+	height, width := len(cells), 0
+	if height > 0 {
+		width = len(cells[0])
+	}
+	fmt.Fprintf(out, "x = %v, y = %v, rule = %v\n", width, height, rule)
+
+	line := 0
+	put := func(count int, tag byte) {
+		token := string(tag)
+		if count > 1 {
+			token = strconv.Itoa(count) + token
+		}
+		if line+len(token) > maxRLELine {
+			out.WriteByte('\n')
+			line = 0
+		}
+		out.WriteString(token)
+		line += len(token)
+	}
+	tag := func(level uint8) byte {
+		state := levelState(level, rule.States)
+		switch {
+		case rule.States > 2 && state == 0:
+			return '.'
+		case rule.States > 2:
+			return byte('A' + state - 1)
+		case state == 0:
+			return 'b'
+		}
+		return 'o'
+	}
+
+	emptyRows := 0
+	for _, row := range cells {
+		end := len(row)
+		for end > 0 && row[end-1] == 0 {
+			end--
+		}
+		if end == 0 {
+			emptyRows++
+			continue
+		}
+		if emptyRows > 0 {
+			put(emptyRows, '$')
+		}
+		for x := 0; x < end; {
+			run := 1
+			for x+run < end && row[x+run] == row[x] {
+				run++
+			}
+			put(run, tag(row[x]))
+			x += run
+		}
+		emptyRows = 1
+	}
+	out.WriteString("!\n")
+	return out.Flush()
+}
+
+// centre places the pattern in the middle of a board of the given size.
+func (p pattern) centre(width, height int) ([][]uint8, error) {
+	if p.Width > width || p.Height > height {
+		return nil, fmt.Errorf("%vx%v pattern does not fit in a %vx%v board", p.Width, p.Height, width, height)
+	}
+	field := newField(height, width)
+	left, top := (width-p.Width)/2, (height-p.Height)/2
+	for y, row := range p.Cells {
+		copy(field[top+y][left:], row)
+	}
+	return field, nil
+}
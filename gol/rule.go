@@ -0,0 +1,140 @@
+package gol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Rule is an outer-totalistic birth/survival rule written in B/S notation, e.g. "B3/S23".
+// Birth[n] reports whether a dead cell with n alive neighbours comes alive,
+// Survival[n] whether an alive cell with n alive neighbours stays alive.
+//
+// Generations rules such as Brian's Brain ("B2/S/C3") also give the number of cell States.
+// An alive cell that does not survive then decays through States-2 dying states before it is dead.
+// Dying cells are not counted as alive neighbours and cannot be born or survive.
+// States of 0 or 2 mean the usual two-state rule.
+//
+// The zero Rule is treated as Conway's Game of Life. Rules parsed from a rulestring always have States set,
+// so that B/S, in which no cell is born or survives, is not the zero Rule.
+type Rule struct {
+	Birth    [9]bool
+	Survival [9]bool
+	States   int
+}
+
+// Some well known rules.
+var (
+	Conway      = MustParseRule("B3/S23")
+	HighLife    = MustParseRule("B36/S23")
+	DayNight    = MustParseRule("B3678/S34678")
+	Seeds       = MustParseRule("B2/S")
+	BriansBrain = MustParseRule("B2/S/C3")
+	StarWars    = MustParseRule("B2/S345/C4")
+)
+
+// ParseRule parses a rulestring in B/S notation such as "B36/S23",
+// optionally followed by a number of states for Generations rules, such as "B2/S/C3".
+// The B and S parts may be given in either order and are case insensitive.
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{States: 2}
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) == 3 && strings.HasPrefix(parts[2], "C") {
+		states, err := strconv.Atoi(parts[2][1:])
+		if err != nil || states < 2 || states > 256 {
+			return rule, errors.New("rule " + s + " has an invalid number of states")
+		}
+		rule.States = states
+		parts = parts[:2]
+	}
+	if len(parts) != 2 {
+		return rule, errors.New("rule " + s + " is not in B/S notation")
+	}
+	seenB, seenS := false, false
+	for _, part := range parts {
+		var counts *[9]bool
+		switch {
+		case strings.HasPrefix(part, "B") && !seenB:
+			counts, seenB = &rule.Birth, true
+		case strings.HasPrefix(part, "S") && !seenS:
+			counts, seenS = &rule.Survival, true
+		default:
+			return rule, errors.New("rule " + s + " is not in B/S notation")
+		}
+		for _, d := range part[1:] {
+			if d < '0' || d > '8' {
+				return rule, errors.New("rule " + s + " has an invalid neighbour count " + string(d))
+			}
+			counts[d-'0'] = true
+		}
+	}
+	return rule, nil
+}
+
+// MustParseRule is like ParseRule but panics if the rulestring cannot be parsed.
+func MustParseRule(s string) Rule {
+	rule, err := ParseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// String returns the rule in B/S notation.
+func (rule Rule) String() string {
+	var sb strings.Builder
+	sb.WriteString("B")
+	for n, born := range rule.Birth {
+		if born {
+			sb.WriteByte(byte('0' + n))
+		}
+	}
+	sb.WriteString("/S")
+	for n, survives := range rule.Survival {
+		if survives {
+			sb.WriteByte(byte('0' + n))
+		}
+	}
+	if rule.States > 2 {
+		sb.WriteString("/C" + strconv.Itoa(rule.States))
+	}
+	return sb.String()
+}
+
+// Set parses a rulestring into the rule, so that a Rule can be used as a flag.Value.
+func (rule *Rule) Set(s string) error {
+	parsed, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*rule = parsed
+	return nil
+}
+
+// orDefault returns Conway's rule in place of the zero Rule.
+func (rule Rule) orDefault() Rule {
+	if rule == (Rule{}) {
+		return Conway
+	}
+	return rule
+}
+
+// next returns the value of a cell in the next turn, given its current value and its number of alive neighbours.
+// Cells are stored as grey levels: 255 is alive, 0 is dead and the dying states of a Generations rule
+// are spread evenly in between, getting darker as they decay.
+func (rule Rule) next(cell uint8, aliveNeighbours int) uint8 {
+	switch {
+	case cell == 0 && rule.Birth[aliveNeighbours]:
+		return 255
+	case cell == 0:
+		return 0
+	case cell == 255 && rule.Survival[aliveNeighbours]:
+		return 255
+	case rule.States <= 2:
+		return 0
+	}
+	// A cell at level 255*m/(States-1) moves down to level 255*(m-1)/(States-1).
+	steps := rule.States - 1
+	m := (int(cell)*steps + 254) / 255
+	return uint8(255 * (m - 1) / steps)
+}
@@ -0,0 +1,67 @@
+package gol
+
+import "errors"
+
+// Topology describes how the edges of the world are joined together.
+// The zero Topology is a torus, where both rows and columns wrap around.
+type Topology int
+
+const (
+	// Torus wraps the top edge to the bottom edge and the left edge to the right edge.
+	Torus Topology = iota
+	// Plane has no wrapping at all: every cell beyond the edges is dead.
+	Plane
+	// Cylinder wraps the left edge to the right edge, while the top and bottom edges are dead.
+	Cylinder
+	// KleinBottle wraps like a torus, except that columns are mirrored when wrapping
+	// between the top and bottom edges.
+	KleinBottle
+	// CrossSurface mirrors columns when wrapping between the top and bottom edges,
+	// and mirrors rows when wrapping between the left and right edges.
+	CrossSurface
+)
+
+var topologyNames = []string{"torus", "plane", "cylinder", "klein", "cross"}
+
+// String returns the name of the topology, as accepted by Set.
+func (topology Topology) String() string {
+	if topology < 0 || int(topology) >= len(topologyNames) {
+		return "Incorrect Topology"
+	}
+	return topologyNames[topology]
+}
+
+// Set parses the name of a topology, so that a Topology can be used as a flag.Value.
+func (topology *Topology) Set(s string) error {
+	for i, name := range topologyNames {
+		if s == name {
+			*topology = Topology(i)
+			return nil
+		}
+	}
+	return errors.New("unknown topology " + s)
+}
+
+// wrap maps the coordinates of a cell just outside a width x height world back onto the world.
+// It returns false if the cell lies beyond an edge that does not wrap, in which case it is dead.
+func (topology Topology) wrap(x, y, width, height int) (int, int, bool) {
+	if x < 0 || x >= width {
+		switch topology {
+		case Plane:
+			return 0, 0, false
+		case CrossSurface:
+			y = height - 1 - y
+		}
+		x = (x + width) % width
+	}
+	if y < 0 || y >= height {
+		switch topology {
+		case Plane, Cylinder:
+			return 0, 0, false
+		case KleinBottle, CrossSurface:
+			x = width - 1 - x
+		}
+		y = (y + height) % height
+	}
+	return x, y, true
+}
@@ -19,6 +19,7 @@ func TestGol(t *testing.T) {
 		{ImageWidth: 512, ImageHeight: 512},
 	}
 	for _, p := range tests {
+		p.Broker = broker
 		for _, turns := range []int{0, 1, 100} {
 			p.Turns = turns
 			expectedAlive := readAliveCells(
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestHashLife tests the HashLife engine on the same images as TestGol and TestPgm,
+// checking both the final alive cells and the output image.
+func TestHashLife(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		p.Engine = gol.HashLifeEngine
+		p.Threads = 1
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+				cellsFromImage := readAliveCells(
+					"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+					p.ImageWidth,
+					p.ImageHeight,
+				)
+				assertEqualBoard(t, cellsFromImage, expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestHashLifeLong compares the HashLife engine with the stepwise engine over thousands of turns,
+// including a rule other than Conway's.
+func TestHashLifeLong(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Turns: 1234, Rule: gol.Conway},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 3000, Rule: gol.Conway},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 2500, Rule: gol.HighLife},
+	}
+	for _, p := range tests {
+		p.Threads = 4
+		testName := fmt.Sprintf("%v-%dx%dx%d", ruleName(p.Rule), p.ImageWidth, p.ImageHeight, p.Turns)
+		t.Run(testName, func(t *testing.T) {
+			expectedAlive := runFinalAlive(t, p)
+			p.Engine = gol.HashLifeEngine
+			assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+		})
+	}
+}
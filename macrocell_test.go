@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestMacrocell converts each of the images to a macrocell pattern and back to pgm and checks that it is unchanged,
+// and runs the 16x16 and 64x64 patterns for 100 turns from a macrocell pattern, saving the result as one.
+func TestMacrocell(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, size := range []int{16, 64, 128, 256, 512} {
+		name := fmt.Sprintf("%vx%v", size, size)
+		pgm, mc := filepath.Join("images", name+".pgm"), filepath.Join(dir, name+".mc")
+		t.Run(name, func(t *testing.T) {
+			if err := gol.Convert(pgm, mc); err != nil {
+				t.Fatal(err)
+			}
+			converted := filepath.Join(dir, name+".pgm")
+			if err := gol.Convert(mc, converted); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(readPgmBytes(pgm), readPgmBytes(converted)) {
+				t.Errorf("converting %v to a macrocell pattern and back changed it", pgm)
+			}
+			if size > 64 {
+				return
+			}
+			p := gol.Params{Turns: 100, Threads: 4, InputPath: mc, OutputDir: dir}
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for range events {
+			}
+			output := filepath.Join(dir, name+"x100")
+			if err := gol.Convert(output+".mc", output+".pgm"); err != nil {
+				t.Fatal(err)
+			}
+			p.ImageWidth, p.ImageHeight = size, size
+			assertEqualBoard(t, readAliveCells(output+".pgm", size, size),
+				readAliveCells(fmt.Sprintf("check/images/%vx100.pgm", name), size, size), p)
+		})
+	}
+}
+
+// TestMacrocellGenerations saves a turn of Brian's Brain as a macrocell pattern and checks that the dying cells
+// are kept when it is loaded again.
+func TestMacrocellGenerations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{Turns: 1, Threads: 4, ImageWidth: 16, ImageHeight: 16, Rule: gol.BriansBrain,
+		OutputDir: dir, OutputTemplate: "brain.mc"}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	p = gol.Params{Threads: 4, InputPath: filepath.Join(dir, "brain.mc"), OutputDir: dir, OutputTemplate: "brain.pgm"}
+	events = make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	expected := readPgmBytes(fmt.Sprintf("check/images/16x16x1-%v.pgm", ruleName(gol.BriansBrain)))
+	if !bytes.Equal(readPgmBytes(filepath.Join(dir, "brain.pgm")), expected) {
+		t.Errorf("loading Brian's Brain from a macrocell pattern does not give the expected grey levels")
+	}
+}
+
+// TestMacrocellSparse saves a glider in a 2048x2048 world, which is 4 MiB as a pgm image,
+// and checks that the macrocell pattern stays small.
+func TestMacrocellSparse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	glider := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(glider, []byte(".O\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := gol.Params{Turns: 4, Threads: 4, ImageWidth: 2048, ImageHeight: 2048, InputPath: glider,
+		OutputDir: dir, OutputTemplate: "glider.mc"}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	mc := filepath.Join(dir, "glider.mc")
+	info, err := os.Stat(mc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1024 {
+		t.Errorf("expected a macrocell pattern of at most 1 KiB, got %v bytes", info.Size())
+	}
+	cells := filepath.Join(dir, "moved.cells")
+	if err := gol.Convert(mc, cells); err != nil {
+		t.Fatal(err)
+	}
+	world, err := ioutil.ReadFile(cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := bytes.Split(world, []byte("\n"))
+	alive := bytes.Count(world, []byte("O"))
+	if len(rows) < 2048 || alive != 5 || rows[1023][1024] != 'O' || rows[1024][1025] != 'O' || string(rows[1025][1023:1026]) != "OOO" {
+		t.Errorf("the glider did not move from the middle of the 2048x2048 world by one cell diagonally")
+	}
+}
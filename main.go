@@ -3,13 +3,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
+	"time"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
+// 'go run . convert <input> <output>' converts between file formats instead.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+		return
+	}
 	runtime.LockOSThread()
 	var params gol.Params
 
@@ -37,6 +45,139 @@ func main() {
         10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.Var(
+		&params.Rule,
+		"rule",
+		"Specify the birth/survival rule in B/S notation, e.g. B36/S23, or B/S/C notation for Generations rules, e.g. B2/S/C3. Defaults to the rule of an RLE pattern, or B3/S23.")
+
+	flag.Var(
+		&params.Topology,
+		"topology",
+		"Specify how the edges of the world are joined: torus, plane, cylinder, klein or cross. Defaults to torus.")
+
+	flag.Var(
+		&params.Backend,
+		"backend",
+		"Specify how the world is stored: byte, or bit for 64 cells per word. Defaults to byte.")
+
+	flag.Var(
+		&params.Engine,
+		"engine",
+		"Specify how turns are computed: step, or hashlife to jump 2^k turns at once. Defaults to step.")
+
+	flag.Var(
+		&params.Workers,
+		"workers",
+		"Specify how worker threads share the world: halo, pool or spawn. Defaults to halo.")
+
+	flag.Var(
+		&params.Tiles,
+		"tiles",
+		"Specify the grid of tiles halo workers split the world into as columns x rows, e.g. 4x4, or auto to choose from the size of the world and the threads. Defaults to auto.")
+
+	flag.StringVar(
+		&params.InputPath,
+		"in",
+		"",
+		"Specify the path of the pbm, pgm or png image to load, taking the width and height from it, or of a pattern to centre in the world: .rle, .cells, .lif, .life or .mc, which gives the size of the world if it has a bounded grid. Defaults to images/<w>x<h>.pgm.")
+
+	flag.IntVar(
+		&params.Threshold,
+		"threshold",
+		0,
+		"Specify the grey level from 1 to 255 at which cells of the input image are alive, or 0 to keep grey levels for Generations rules. Defaults to 0.")
+
+	flag.StringVar(
+		&params.OutputDir,
+		"out",
+		"out",
+		"Specify the directory to write images and checkpoints to. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputTemplate,
+		"out-template",
+		"",
+		"Specify the template for the names of output images, ending in .pgm, .png, .rle, .cells, .lif, .life or .mc. {name} is the name of the input image, and {width}, {height} and {turn} can be formatted like {turn:08d}. Defaults to {width}x{height}x{turn} with the extension of the input.")
+
+	flag.IntVar(
+		&params.PngScale,
+		"png-scale",
+		1,
+		"Specify the size in pixels of the square each cell is drawn as in png images. Defaults to 1.")
+
+	flag.Var(
+		&params.Palette,
+		"palette",
+		"Specify the colours of dead and alive cells in png images: grey, inverted, or #rrggbb:#rrggbb for dead and alive. Defaults to grey.")
+
+	flag.BoolVar(
+		&params.AtomicOutput,
+		"atomic",
+		false,
+		"Write output files to a temporary file first and rename it once complete, so that they are never seen half written.")
+
+	flag.IntVar(
+		&params.CheckpointEvery,
+		"checkpoint-every",
+		0,
+		"Specify the number of turns between checkpoints written to the output directory, or 0 to only write them when s is pressed. Defaults to 0.")
+
+	flag.StringVar(
+		&params.Resume,
+		"resume",
+		"",
+		"Specify the path of a checkpoint to resume the simulation from, using the rule and topology it was written with. -w and -h must match it.")
+
+	flag.StringVar(
+		&params.Broker,
+		"broker",
+		"",
+		"Specify the address of a broker to run the simulation on, e.g. localhost:8030. Defaults to running locally.")
+
+	flag.BoolVar(
+		&params.Attach,
+		"attach",
+		false,
+		"Attach to the simulation already running on the broker instead of starting a new one. -w and -h must match it.")
+
+	var gifOptions gol.GifOptions
+
+	flag.StringVar(
+		&gifOptions.Path,
+		"gif",
+		"",
+		"Specify the path of an animated GIF to record the run to, drawn with the colours of -palette. Defaults to not recording.")
+
+	flag.IntVar(
+		&gifOptions.From,
+		"gif-from",
+		1,
+		"Specify the first completed turn recorded in the GIF. Defaults to 1.")
+
+	flag.IntVar(
+		&gifOptions.To,
+		"gif-to",
+		0,
+		"Specify the last completed turn recorded in the GIF, or 0 to record to the end of the run. Defaults to 0.")
+
+	flag.IntVar(
+		&gifOptions.Stride,
+		"gif-stride",
+		1,
+		"Specify the number of turns between frames of the GIF. Defaults to 1.")
+
+	flag.IntVar(
+		&gifOptions.Scale,
+		"gif-scale",
+		1,
+		"Specify the size in pixels of the square each cell is drawn as in the GIF. Defaults to 1.")
+
+	flag.DurationVar(
+		&gifOptions.Delay,
+		"gif-delay",
+		100*time.Millisecond,
+		"Specify the time each frame of the GIF is shown for, e.g. 50ms, which is rounded to hundredths of a second. Defaults to 100ms.")
+
 	noVis := flag.Bool(
 		"noVis",
 		false,
@@ -44,24 +185,84 @@ func main() {
 
 	flag.Parse()
 
+	// The window is sized from the input, unless it is only on the broker.
+	if params.InputPath != "" && params.Broker == "" {
+		width, height, err := gol.BoardSize(params)
+		util.Check(err)
+		params.ImageWidth, params.ImageHeight = width, height
+	}
+
+	if params.InputPath != "" {
+		fmt.Println("Input:", params.InputPath)
+	}
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	if params.Rule != (gol.Rule{}) {
+		fmt.Println("Rule:", params.Rule)
+	}
+	fmt.Println("Topology:", params.Topology)
+	fmt.Println("Backend:", params.Backend)
+	fmt.Println("Engine:", params.Engine)
+	fmt.Println("Workers:", params.Workers)
+	fmt.Println("Tiles:", params.Tiles)
+	if params.Resume != "" {
+		fmt.Println("Resume:", params.Resume)
+	}
+	if params.Broker != "" {
+		fmt.Println("Broker:", params.Broker)
+	}
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
 
+	// The recorder passes the events on to the window once it has drawn them.
+	var shown <-chan gol.Event = events
+	var recorded chan error
+	if gifOptions.Path != "" {
+		gifOptions.Palette = params.Palette
+		forwarded := make(chan gol.Event, 1000)
+		recorded = make(chan error, 1)
+		go func() {
+			recorded <- gol.RecordGif(gifOptions, params.ImageWidth, params.ImageHeight, events, forwarded)
+		}()
+		shown = forwarded
+	}
+
 	go gol.Run(params, events, keyPresses)
 	if !(*noVis) {
-		sdl.Run(params, events, keyPresses)
+		sdl.Run(params, shown, keyPresses)
 	} else {
-		complete := false
-		for !complete {
-			event := <-events
-			switch event.(type) {
-			case gol.FinalTurnComplete:
-				complete = true
+		// The events are closed after FinalTurnComplete, or when the controller is detached from a broker.
+		for event := range shown {
+			if e, ok := event.(gol.Error); ok {
+				fmt.Fprintln(os.Stderr, e.Err)
+				os.Exit(1)
 			}
 		}
 	}
+
+	if recorded != nil {
+		for range shown {
+		}
+		if err := <-recorded; err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Recorded", gifOptions.Path)
+	}
+}
+
+// convert converts an image or pattern to the format given by the extension of the output path,
+// e.g. 'go run . convert glider.rle glider.cells'.
+func convert(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: convert <input> <output>")
+		fmt.Fprintln(os.Stderr, "Formats are chosen by extension: .png, .rle, .cells, .lif, .life or .mc, and pgm for any other.")
+		os.Exit(2)
+	}
+	if err := gol.Convert(args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"uk.ac.bris.cs/gameoflife/gol"
 )
@@ -14,6 +17,7 @@ func TestPgm(t *testing.T) {
 		{ImageWidth: 512, ImageHeight: 512},
 	}
 	for _, p := range tests {
+		p.Broker = broker
 		for _, turns := range []int{0, 1, 100} {
 			p.Turns = turns
 			expectedAlive := readAliveCells(
@@ -40,3 +44,82 @@ func TestPgm(t *testing.T) {
 		}
 	}
 }
+
+// TestInputPath copies the 16x16 and 64x64 images to another directory and runs them for 100 turns from there,
+// without giving their size.
+func TestInputPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, size := range []int{16, 64} {
+		data, err := ioutil.ReadFile(fmt.Sprintf("images/%vx%v.pgm", size, size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("pattern-%v.pgm", size))
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		p := gol.Params{Turns: 100, Threads: 4, InputPath: path}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			cells := runFinalAlive(t, p)
+			p.ImageWidth, p.ImageHeight = size, size
+			expected := readAliveCells(fmt.Sprintf("check/images/%vx%vx100.pgm", size, size), size, size)
+			assertEqualBoard(t, cells, expected, p)
+		})
+	}
+}
+
+// TestOutputTemplate writes the images and checkpoints of a run atomically to another directory,
+// named after the input image and the zero padded turn.
+func TestOutputTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("images/16x16.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "pattern.pgm")
+	if err := ioutil.WriteFile(input, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "runs", "1")
+	p := gol.Params{
+		Turns:           100,
+		Threads:         4,
+		InputPath:       input,
+		OutputDir:       output,
+		OutputTemplate:  "{name}-{turn:08d}.pgm",
+		AtomicOutput:    true,
+		CheckpointEvery: 50,
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var filenames []string
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ImageOutputComplete:
+			filenames = append(filenames, e.Filename)
+		case gol.CheckpointComplete:
+			filenames = append(filenames, e.Filename)
+		}
+	}
+	if fmt.Sprint(filenames) != "[pattern-00000050.checkpoint pattern-00000100.checkpoint pattern-00000100.pgm]" {
+		t.Errorf("unexpected files written: %v", filenames)
+	}
+	files, err := ioutil.ReadDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files in %v, found %v", output, len(files))
+	}
+	p.ImageWidth, p.ImageHeight = 16, 16
+	assertEqualBoard(t, readAliveCells(filepath.Join(output, "pattern-00000100.pgm"), 16, 16),
+		readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestPng saves the 64x64 image after 100 turns as a png image with a scale of 3 and the inverted palette,
+// checks its pixels against the expected cells, and loads it back into a world to check that it is unchanged.
+func TestPng(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var palette gol.Palette
+	if err := palette.Set("inverted"); err != nil {
+		t.Fatal(err)
+	}
+	p := gol.Params{
+		Turns:          100,
+		Threads:        4,
+		ImageWidth:     64,
+		ImageHeight:    64,
+		OutputDir:      dir,
+		OutputTemplate: "{width}x{height}x{turn}.png",
+		PngScale:       3,
+		Palette:        palette,
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	expected := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+
+	path := filepath.Join(dir, "64x64x100.png")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 192 || size.Y != 192 {
+		t.Fatalf("expected a 192x192 image, got %vx%v", size.X, size.Y)
+	}
+	alive := make(map[[2]int]bool)
+	for _, cell := range expected {
+		alive[[2]int{cell.X, cell.Y}] = true
+	}
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 192; x++ {
+			grey := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			if (grey == 0) != alive[[2]int{x / 3, y / 3}] {
+				t.Fatalf("pixel %v,%v of cell %v,%v has grey level %v", x, y, x/3, y/3, grey)
+			}
+		}
+	}
+
+	q := gol.Params{Threads: 4, InputPath: path, OutputDir: dir, PngScale: 3, Palette: palette}
+	cells := runFinalAlive(t, q)
+	q.ImageWidth, q.ImageHeight = 64, 64
+	assertEqualBoard(t, cells, expected, q)
+}
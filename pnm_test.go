@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPnm loads a glider from plain and raw PBM and PGM images with comments and different maxvals
+// into a world the size of the image.
+func TestPnm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	images := map[string]string{
+		"plain.pbm": "P1\n# A glider\n4 4\n0100\n0010 # the middle row\n1110\n0 0 0 0\n",
+		"raw.pbm":   "P4 # A glider\n4 4\n\x40\x20\xe0\x00",
+		"plain.pgm": "P2\n4 4\n# A glider\n15\n0 15 0 0\n0 0 15 0\n15 15 15 0\n0 0 0 0\n",
+		"raw.pgm":   "P5\n4 4 # A glider\n7\n\x00\x07\x00\x00\x00\x00\x07\x00\x07\x07\x07\x00\x00\x00\x00\x00",
+		"16bit.pgm": "P5\n4 4\n65535\n" + strings.Repeat("\x00", 2) + "\xff\xff" + strings.Repeat("\x00", 8) +
+			"\xff\xff" + strings.Repeat("\x00", 2) + strings.Repeat("\xff", 6) + strings.Repeat("\x00", 10),
+	}
+	expected := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	for name, image := range images {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(image), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p := gol.Params{Threads: 4, InputPath: path, OutputDir: dir}
+		t.Run(name, func(t *testing.T) {
+			p.ImageWidth, p.ImageHeight = 4, 4
+			assertEqualBoard(t, runFinalAlive(t, p), expected, p)
+		})
+	}
+}
+
+// TestPnmThreshold loads a grey glider, whose cells are only alive with a threshold below their level.
+func TestPnmThreshold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "grey.pgm")
+	image := "P2\n4 4\n15\n0 8 0 0\n0 0 8 0\n8 8 8 0\n0 0 0 7\n"
+	if err := ioutil.WriteFile(path, []byte(image), 0644); err != nil {
+		t.Fatal(err)
+	}
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	for _, test := range []struct {
+		threshold int
+		expected  []util.Cell
+	}{
+		{0, nil},
+		{128, glider},
+		{255, nil},
+		{100, append(glider, util.Cell{X: 3, Y: 3})},
+	} {
+		p := gol.Params{Threads: 4, ImageWidth: 4, ImageHeight: 4, InputPath: path, OutputDir: dir, Threshold: test.threshold}
+		assertEqualBoard(t, runFinalAlive(t, p), test.expected, p)
+	}
+}
+
+// TestPnmRaw converts a raw pgm image whose grey levels include whitespace characters and checks that it is unchanged.
+func TestPnmRaw(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image := []byte("P5\n4 2\n255\n\x0a\x20\x09\xff\x0d\x00\x0c\x0b")
+	in, out := filepath.Join(dir, "in.pgm"), filepath.Join(dir, "out.pgm")
+	if err := ioutil.WriteFile(in, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gol.Convert(in, out); err != nil {
+		t.Fatal(err)
+	}
+	if converted, _ := ioutil.ReadFile(out); !bytes.Equal(converted, image) {
+		t.Errorf("converting %q gave %q", image, converted)
+	}
+}
+
+// TestPnmErrors checks that malformed images are reported as errors.
+func TestPnmErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := map[string]string{
+		"":                               "file is empty",
+		"P6\n1 1\n255\n\x00\x00\x00":     `magic number "P6"`,
+		"P5\n4\n":                        "reading height",
+		"P5\n-4 4\n255\n":                `invalid width "-4"`,
+		"P2\n2 2\n255\n0 0 x 0\n":        `invalid pixel value "x"`,
+		"P2\n2 2\n15\n0 0 16 0\n":        "pixel 0,1: pixel value 16 is above the maxval of 15",
+		"P1\n2 2\n0 1\n2 0\n":            "invalid pixel value '2'",
+		"P5\n4 4\n255\n\x00\x00\x00\x00": "image data ends in row 2 of 4",
+		"P2\n2 2\n255\n0 0 0\n":          "image data ends in row 2 of 2",
+	}
+	out := filepath.Join(dir, "out.pgm")
+	for image, expected := range tests {
+		in := filepath.Join(dir, "in.pgm")
+		if err := ioutil.WriteFile(in, []byte(image), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := gol.Convert(in, out)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("reading %q: expected an error containing %q, got %v", image, expected, err)
+		}
+	}
+}
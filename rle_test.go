@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRLE loads a glider from an RLE pattern into the middle of an 8x8 world, runs it for 4 turns,
+// and checks that the world saved as an RLE pattern holds the glider moved one cell diagonally.
+func TestRLE(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	glider := filepath.Join(dir, "glider.rle")
+	pattern := "#N Glider\n#C A comment\nx = 3, y = 3, rule = B3/S23\nbob$2bo$\n3o!\n"
+	if err := ioutil.WriteFile(glider, []byte(pattern), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Threads: 4, ImageWidth: 8, ImageHeight: 8, InputPath: glider, OutputDir: dir}
+	initial := runFinalAlive(t, p)
+	expected := []util.Cell{{X: 3, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 4}, {X: 3, Y: 4}, {X: 4, Y: 4}}
+	assertEqualBoard(t, initial, expected, p)
+
+	p.Turns = 4
+	runFinalAlive(t, p)
+	saved := filepath.Join(dir, "8x8x4.rle")
+	if _, err := os.Stat(saved); err != nil {
+		t.Fatalf("the world was not saved as an RLE pattern: %v", err)
+	}
+	for i := range expected {
+		expected[i].X++
+		expected[i].Y++
+	}
+	p.InputPath = saved
+	p.ImageWidth, p.ImageHeight, p.Turns = 0, 0, 0
+	assertEqualBoard(t, runFinalAlive(t, p), expected, p)
+}
+
+// TestRLEGenerations saves the 64x64 image after 1 turn of Brian's Brain as an RLE pattern, and checks that
+// running it for another 99 turns with the rule from the pattern gives the expected image, dying cells included.
+func TestRLEGenerations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{
+		Turns:          1,
+		Threads:        4,
+		ImageWidth:     64,
+		ImageHeight:    64,
+		Rule:           gol.BriansBrain,
+		OutputDir:      dir,
+		OutputTemplate: "{name}-{turn}.rle",
+	}
+	runFinalAlive(t, p)
+
+	resumed := gol.Params{
+		Turns:          99,
+		Threads:        4,
+		InputPath:      filepath.Join(dir, "64x64-1.rle"),
+		OutputDir:      dir,
+		OutputTemplate: "{name}-{turn}.pgm",
+	}
+	runFinalAlive(t, resumed)
+	expected := readPgmBytes(fmt.Sprintf("check/images/64x64x100-%v.pgm", ruleName(gol.BriansBrain)))
+	if !bytes.Equal(readPgmBytes(filepath.Join(dir, "64x64-1-99.pgm")), expected) {
+		t.Errorf("the pattern did not give the expected image after 100 turns")
+	}
+}
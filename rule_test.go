@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRule tests 16x16 and 64x64 images on 1 and 100 turns under HighLife, Day & Night and Seeds using 1-16 worker threads.
+func TestRule(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, rule := range []gol.Rule{gol.HighLife, gol.DayNight, gol.Seeds} {
+		for _, p := range tests {
+			p.Rule = rule
+			for _, turns := range []int{1, 100} {
+				p.Turns = turns
+				expectedAlive := readAliveCells(
+					"check/images/"+fmt.Sprintf("%vx%vx%v-%v.pgm", p.ImageWidth, p.ImageHeight, turns, ruleName(rule)),
+					p.ImageWidth,
+					p.ImageHeight,
+				)
+				for threads := 1; threads <= 16; threads++ {
+					p.Threads = threads
+					testName := fmt.Sprintf("%v-%dx%dx%d-%d", ruleName(rule), p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+					t.Run(testName, func(t *testing.T) {
+						assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestEmptyRule runs the 16x16 image for 1 turn under B/S, in which no cell is born or survives,
+// and checks that it is not run as Conway's Game of Life.
+func TestEmptyRule(t *testing.T) {
+	p := gol.Params{Turns: 1, Threads: 4, ImageWidth: 16, ImageHeight: 16, Rule: gol.MustParseRule("B/S")}
+	if alive := runFinalAlive(t, p); len(alive) != 0 {
+		t.Errorf("expected no alive cells after 1 turn of B/S, but got %v", len(alive))
+	}
+}
+
+// TestGenerations tests 16x16 and 64x64 images on 1 and 100 turns under Brian's Brain and Star Wars using 1-16 worker threads.
+// The output image must contain the dying cells as grey levels.
+func TestGenerations(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, rule := range []gol.Rule{gol.BriansBrain, gol.StarWars} {
+		for _, p := range tests {
+			p.Rule = rule
+			for _, turns := range []int{1, 100} {
+				p.Turns = turns
+				expected := readPgmBytes("check/images/" + fmt.Sprintf("%vx%vx%v-%v.pgm", p.ImageWidth, p.ImageHeight, turns, ruleName(rule)))
+				var expectedAlive []util.Cell
+				for i, cell := range expected {
+					if cell == 255 {
+						expectedAlive = append(expectedAlive, util.Cell{X: i % p.ImageWidth, Y: i / p.ImageWidth})
+					}
+				}
+				for threads := 1; threads <= 16; threads++ {
+					p.Threads = threads
+					testName := fmt.Sprintf("%v-%dx%dx%d-%d", ruleName(rule), p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+					t.Run(testName, func(t *testing.T) {
+						assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+						given := readPgmBytes("out/" + fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns))
+						if !bytes.Equal(given, expected) {
+							t.Errorf("output image for %v does not contain the expected grey levels", testName)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// ruleName is the name used for rule-specific images in check/images, e.g. B36S23 or B2S-C3.
+func ruleName(rule gol.Rule) string {
+	return strings.NewReplacer("/S", "S", "/C", "-C").Replace(rule.String())
+}
+
+// readPgmBytes returns the pixels of a binary pgm image.
+func readPgmBytes(path string) []byte {
+	data, ioError := ioutil.ReadFile(path)
+	util.Check(ioError)
+	fields := bytes.SplitN(data, []byte("\n"), 4)
+	return fields[3]
+}
+
+// TestParseRule checks that rulestrings are parsed and printed in canonical B/S notation.
+func TestParseRule(t *testing.T) {
+	valid := map[string]string{
+		"B3/S23":       "B3/S23",
+		"b36/s23":      "B36/S23",
+		"S23/B3":       "B3/S23",
+		"B2/S":         "B2/S",
+		"B3678/S34678": "B3678/S34678",
+		"B2/S/C3":      "B2/S/C3",
+		"B2/S345/C4":   "B2/S345/C4",
+		"B3/S23/C2":    "B3/S23",
+	}
+	for input, expected := range valid {
+		rule, err := gol.ParseRule(input)
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned error: %v", input, err)
+		} else if rule.String() != expected {
+			t.Errorf("ParseRule(%q) = %v, expected %v", input, rule, expected)
+		}
+	}
+	for _, input := range []string{"", "B3", "23/3", "B3/S29", "B3/B3", "B2/S/C1", "B2/S/3"} {
+		if _, err := gol.ParseRule(input); err == nil {
+			t.Errorf("ParseRule(%q) should have returned an error", input)
+		}
+	}
+}
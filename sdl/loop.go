@@ -35,7 +35,7 @@ sdlLoop:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				w.SetPixelValue(e.Cell.X, e.Cell.Y, e.Value)
 			case gol.TurnComplete:
 				w.RenderFrame()
 			case gol.FinalTurnComplete:
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestTiles tests 16x16, 64x64 and 512x512 images on 100 turns split into a range of tile grids,
+// on every topology the halo workers support and on both backends.
+func TestTiles(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	grids := []gol.Grid{{}, {Columns: 1, Rows: 1}, {Columns: 4, Rows: 4}, {Columns: 3, Rows: 5}, {Columns: 16, Rows: 1}, {Columns: 7, Rows: 2}}
+	for _, topology := range []gol.Topology{gol.Torus, gol.Plane, gol.Cylinder, gol.KleinBottle} {
+		for _, p := range tests {
+			if topology != gol.Torus && p.ImageWidth == 512 {
+				continue
+			}
+			p.Turns = 100
+			p.Threads = 8
+			p.Topology = topology
+			golden := fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns)
+			if topology != gol.Torus {
+				golden = fmt.Sprintf("%vx%vx%v-%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns, topology)
+			}
+			expectedAlive := readAliveCells("check/images/"+golden, p.ImageWidth, p.ImageHeight)
+			for _, backend := range []gol.Backend{gol.ByteBackend, gol.BitBackend} {
+				p.Backend = backend
+				for _, grid := range grids {
+					p.Tiles = grid
+					testName := fmt.Sprintf("%v-%v-%dx%dx%d-%v", topology, backend, p.ImageWidth, p.ImageHeight, p.Turns, grid)
+					t.Run(testName, func(t *testing.T) {
+						assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestTilesSkipped runs a 64x64 image until most of it has settled, checking that settled tiles are skipped
+// and that the final board is the same as one computed without skipping.
+func TestTilesSkipped(t *testing.T) {
+	p := gol.Params{
+		Turns:       500,
+		Threads:     8,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		Tiles:       gol.Grid{Columns: 8, Rows: 8},
+	}
+	for _, backend := range []gol.Backend{gol.ByteBackend, gol.BitBackend} {
+		p.Backend = backend
+		t.Run(backend.String(), func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var cells []util.Cell
+			turns, skipped := 0, 0
+			for event := range events {
+				switch e := event.(type) {
+				case gol.TilesSkipped:
+					if e.Tiles != 64 {
+						t.Fatalf("expected 64 tiles, got %v", e.Tiles)
+					}
+					if e.CompletedTurns != turns {
+						t.Fatalf("expected statistics for turn %v, got turn %v", turns, e.CompletedTurns)
+					}
+					turns++
+					skipped += e.Skipped
+				case gol.FinalTurnComplete:
+					cells = e.Alive
+				}
+			}
+			if turns != p.Turns {
+				t.Errorf("expected statistics for %v turns, got %v", p.Turns, turns)
+			}
+			if skipped == 0 {
+				t.Error("no tiles were skipped")
+			}
+
+			pool := p
+			pool.Workers = gol.PoolWorkers
+			assertEqualBoard(t, cells, runFinalAlive(t, pool), p)
+		})
+	}
+}
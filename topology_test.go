@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestTopology tests 16x16 and 64x64 images on 1 and 100 turns on every non-toroidal topology using 1-16 worker threads.
+func TestTopology(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, topology := range []gol.Topology{gol.Plane, gol.Cylinder, gol.KleinBottle, gol.CrossSurface} {
+		for _, p := range tests {
+			p.Topology = topology
+			for _, turns := range []int{1, 100} {
+				p.Turns = turns
+				expectedAlive := readAliveCells(
+					"check/images/"+fmt.Sprintf("%vx%vx%v-%v.pgm", p.ImageWidth, p.ImageHeight, turns, topology),
+					p.ImageWidth,
+					p.ImageHeight,
+				)
+				for threads := 1; threads <= 16; threads++ {
+					p.Threads = threads
+					testName := fmt.Sprintf("%v-%dx%dx%d-%d", topology, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+					t.Run(testName, func(t *testing.T) {
+						assertEqualBoard(t, runFinalAlive(t, p), expectedAlive, p)
+					})
+				}
+			}
+		}
+	}
+}
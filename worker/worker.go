@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// main starts a worker, which registers with a broker and computes strips of the world for it.
+// Interrupting the worker makes it leave the broker between turns, so that a running simulation carries on without it.
+func main() {
+	address := flag.String(
+		"address",
+		"localhost:8031",
+		"Specify the address to listen on, which the broker connects to. Defaults to localhost:8031.")
+
+	broker := flag.String(
+		"broker",
+		"localhost:8030",
+		"Specify the address of the broker to register with. Defaults to localhost:8030.")
+
+	flag.Parse()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	leave := make(chan bool)
+	go func() {
+		<-signals
+		close(leave)
+	}()
+
+	listener, err := net.Listen("tcp", *address)
+	util.Check(err)
+	util.Check(gol.ServeWorker(listener, *address, *broker, leave))
+}